@@ -7,15 +7,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"auth-service/internal/config"
 	"auth-service/internal/database"
 	"auth-service/internal/handlers"
+	"auth-service/internal/mail"
 	"auth-service/internal/middleware"
+	"auth-service/internal/passhash"
 	"auth-service/internal/repository"
 	"auth-service/internal/service"
+	"auth-service/internal/sms"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -42,19 +46,112 @@ func main() {
 	defer redisClient.Close()
 
 	// Initialize repositories
-	userRepo := repository.NewUserRepository(db)
+	// Tables with row-level security are only reached through the
+	// tenant-scoped executor; tenants has no policy
+	tenantDB := database.NewTenantDB(db, cfg.DatabaseTenantRole)
+
+	userRepo := repository.NewUserRepository(tenantDB)
 	tenantRepo := repository.NewTenantRepository(db)
+	mfaRepo := repository.NewMFARepository(tenantDB)
+	oauthClientRepo := repository.NewOAuthClientRepository(tenantDB)
+	roleRepo := repository.NewRoleRepository(tenantDB)
+	auditRepo := repository.NewAuditRepository(tenantDB)
+	privacyRepo := repository.NewPrivacyRepository(tenantDB)
+	userImportRepo := repository.NewUserImportRepository(tenantDB)
+	invitationRepo := repository.NewInvitationRepository(tenantDB)
+	apiKeyRepo := repository.NewAPIKeyRepository(tenantDB)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(tenantDB)
+
+	// Initialize JWT signing keys
+	keyManager, err := service.NewKeyManager(redisClient, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	keyManager.Start(backgroundCtx)
+
+	// Initialize tenant resolution
+	tenantResolver := service.NewTenantResolver(tenantRepo, redisClient, cfg)
+	tenantResolver.Start(backgroundCtx)
+
+	// Initialize mail sender
+	mailer, err := mail.NewSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mail sender: %v", err)
+	}
+
+	// Initialize SMS sender
+	smsSender, err := sms.NewSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize SMS sender: %v", err)
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, tenantRepo, redisClient, cfg)
-	userService := service.NewUserService(userRepo)
+	loginThrottler, err := service.NewLoginThrottler(redisClient, service.NewSecurityEventPublisher(redisClient), cfg.LoginThrottlePolicies)
+	if err != nil {
+		log.Fatalf("Failed to initialize login throttling: %v", err)
+	}
+
+	auditService := service.NewAuditService(auditRepo)
+	verificationService := service.NewEmailVerificationService(userRepo, redisClient, mailer, cfg)
+
+	mfaService, err := service.NewMFAService(mfaRepo, userRepo, redisClient, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize MFA service: %v", err)
+	}
+
+	passwordHasher, err := passhash.NewHasher(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hashing: %v", err)
+	}
+
+	// Breached password screening works offline from a list of hash prefixes
+	breachedPasswords, err := service.LoadBreachedPasswords(cfg.BreachedPasswordsFile)
+	if err != nil {
+		log.Fatalf("Failed to load breached passwords: %v", err)
+	}
+	passwordPolicy := service.NewPasswordPolicyService(userRepo, passwordHistoryRepo, tenantRepo, passwordHasher, breachedPasswords)
+
+	roleService := service.NewRoleService(roleRepo, userRepo, auditService, redisClient)
+	authService := service.NewAuthService(userRepo, tenantRepo, verificationService, mfaService, roleService, auditService, passwordPolicy, loginThrottler, redisClient, keyManager, cfg)
+	userService := service.NewUserService(userRepo, passwordPolicy, auditService, redisClient)
+	passwordResetService := service.NewPasswordResetService(userRepo, authService, passwordPolicy, auditService, redisClient, mailer, cfg)
+	phoneOTPService := service.NewPhoneOTPService(userRepo, authService, smsSender, redisClient, cfg)
+	oidcService := service.NewOIDCService(oauthClientRepo, userRepo, authService, keyManager, redisClient, cfg)
+	sessionService := service.NewSessionService(userRepo, redisClient)
+	privacyService := service.NewPrivacyService(userRepo, privacyRepo, auditRepo, auditService, redisClient, cfg)
+	privacyService.Start(backgroundCtx)
+	userImportService := service.NewUserImportService(userImportRepo, userRepo, passwordResetService, auditService, cfg)
+	userImportService.Start(backgroundCtx)
+	invitationService := service.NewInvitationService(invitationRepo, userRepo, authService, passwordPolicy, auditService, mailer, smsSender, cfg)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, auditService)
+	impersonationService := service.NewImpersonationService(userRepo, roleService, authService, auditService, cfg)
+	forwardAuthService := service.NewForwardAuthService(authService, apiKeyService, redisClient, cfg)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
+	wellKnownHandler := handlers.NewWellKnownHandler(keyManager, cfg)
+	passwordHandler := handlers.NewPasswordHandler(passwordResetService)
+	verificationHandler := handlers.NewVerificationHandler(verificationService)
+	adminHandler := handlers.NewAdminHandler(authService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, authService)
+	otpHandler := handlers.NewOTPHandler(phoneOTPService)
+	oauthHandler := handlers.NewOAuthHandler(oidcService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	userImportHandler := handlers.NewUserImportHandler(userImportService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService)
+	forwardAuthHandler := handlers.NewForwardAuthHandler(forwardAuthService)
 
 	// Setup router
-	router := setupRouter(cfg, authHandler, userHandler, authService)
+	router := setupRouter(cfg, authHandler, userHandler, wellKnownHandler, passwordHandler, verificationHandler, adminHandler, mfaHandler, otpHandler, oauthHandler, roleHandler, sessionHandler, auditHandler, privacyHandler, userImportHandler, invitationHandler, apiKeyHandler, impersonationHandler, forwardAuthHandler, tenantResolver, authService, apiKeyService, impersonationService)
 
 	// Start server
 	srv := &http.Server{
@@ -86,16 +183,22 @@ func main() {
 	log.Println("Server exited")
 }
 
-func setupRouter(cfg *config.Config, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, authService *service.AuthService) *gin.Engine {
+func setupRouter(cfg *config.Config, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, wellKnownHandler *handlers.WellKnownHandler, passwordHandler *handlers.PasswordHandler, verificationHandler *handlers.VerificationHandler, adminHandler *handlers.AdminHandler, mfaHandler *handlers.MFAHandler, otpHandler *handlers.OTPHandler, oauthHandler *handlers.OAuthHandler, roleHandler *handlers.RoleHandler, sessionHandler *handlers.SessionHandler, auditHandler *handlers.AuditHandler, privacyHandler *handlers.PrivacyHandler, userImportHandler *handlers.UserImportHandler, invitationHandler *handlers.InvitationHandler, apiKeyHandler *handlers.APIKeyHandler, impersonationHandler *handlers.ImpersonationHandler, forwardAuthHandler *handlers.ForwardAuthHandler, tenantResolver *service.TenantResolver, authService *service.AuthService, apiKeyService *service.APIKeyService, impersonationService *service.ImpersonationService) *gin.Engine {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
+	// X-Forwarded-For is only believed from the configured proxies, otherwise
+	// any client could pick the IP that allow-lists and rate limits see
+	if err := router.SetTrustedProxies(splitList(cfg.TrustedProxies)); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
-	router.Use(middleware.TenantContext())
+	router.Use(middleware.ClientInfo())
+	router.Use(middleware.TenantContext(tenantResolver))
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -106,6 +209,13 @@ func setupRouter(cfg *config.Config, authHandler *handlers.AuthHandler, userHand
 		})
 	})
 
+	// Token verification keys and discovery
+	wellKnown := router.Group("/.well-known")
+	{
+		wellKnown.GET("/jwks.json", wellKnownHandler.JWKS)
+		wellKnown.GET("/openid-configuration", wellKnownHandler.OpenIDConfiguration)
+	}
+
 	// API v1
 	v1 := router.Group("/api/v1")
 	{
@@ -115,41 +225,133 @@ func setupRouter(cfg *config.Config, authHandler *handlers.AuthHandler, userHand
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
-			auth.POST("/forgot-password", authHandler.ForgotPassword)
-			auth.POST("/reset-password", authHandler.ResetPassword)
-			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/forgot-password", passwordHandler.ForgotPassword)
+			auth.POST("/reset-password", passwordHandler.ResetPassword)
+			auth.POST("/verify-email", verificationHandler.VerifyEmail)
+			auth.POST("/resend-verification", verificationHandler.ResendVerification)
+			auth.POST("/mfa/enroll", mfaHandler.LoginEnroll)
+			auth.POST("/mfa/verify", mfaHandler.LoginVerify)
+			auth.POST("/otp/request", otpHandler.RequestCode)
+			auth.POST("/otp/verify", otpHandler.VerifyCode)
+			auth.GET("/invitation", invitationHandler.Lookup)
+			auth.POST("/accept-invitation", invitationHandler.Accept)
+
+			// Forward auth for the API gateway, any method of the original
+			// request
+			auth.Any("/verify", forwardAuthHandler.Verify)
+		}
+
+		// OAuth 2.0 / OpenID Connect
+		oauth := v1.Group("/oauth")
+		{
+			oauth.GET("/authorize", oauthHandler.Authorize)
+			oauth.POST("/token", oauthHandler.Token)
+			oauth.POST("/introspect", oauthHandler.Introspect)
 		}
 
 		// Protected routes
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(authService))
+		protected.Use(middleware.AuthMiddleware(authService, apiKeyService), middleware.AuditImpersonation(impersonationService))
 		{
 			// User management
 			users := protected.Group("/users")
+			users.Use(middleware.FirstPartyOnly())
 			{
 				users.GET("/me", userHandler.GetCurrentUser)
 				users.PUT("/me", userHandler.UpdateCurrentUser)
 				users.PUT("/me/password", userHandler.ChangePassword)
-				users.DELETE("/me", userHandler.DeleteCurrentUser)
+
+				// Personal data
+				users.GET("/me/data-export", privacyHandler.Export)
+				users.DELETE("/me", privacyHandler.RequestErasure)
+				users.DELETE("/me/erasure", privacyHandler.CancelErasure)
+
+				// Two-factor authentication
+				users.POST("/me/mfa/totp/enroll", mfaHandler.Enroll)
+				users.POST("/me/mfa/totp/confirm", mfaHandler.Confirm)
+				users.DELETE("/me/mfa/totp", mfaHandler.Disable)
+				users.POST("/me/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+				// Login sessions and devices
+				users.GET("/me/sessions", sessionHandler.ListMine)
+				users.DELETE("/me/sessions/:id", sessionHandler.RevokeMine)
 			}
 
 			// Logout
 			protected.POST("/auth/logout", authHandler.Logout)
+			protected.POST("/auth/logout-all", authHandler.LogoutAll)
+
+			// OpenID Connect
+			protected.POST("/oauth/authorize", middleware.FirstPartyOnly(), oauthHandler.ApproveAuthorization)
+			protected.GET("/oauth/userinfo", oauthHandler.UserInfo)
+			protected.POST("/oauth/userinfo", oauthHandler.UserInfo)
 		}
 
-		// Admin routes
+		// Admin routes, each guarded by the permission it needs so custom
+		// roles can open parts of the admin API to staff
 		admin := v1.Group("/admin")
-		admin.Use(middleware.AuthMiddleware(authService))
-		admin.Use(middleware.RoleMiddleware("admin", "super_admin"))
+		admin.Use(middleware.AuthMiddleware(authService, apiKeyService), middleware.AuditImpersonation(impersonationService))
 		{
-			admin.GET("/users", userHandler.ListUsers)
-			admin.GET("/users/:id", userHandler.GetUser)
-			admin.PUT("/users/:id", userHandler.UpdateUser)
-			admin.DELETE("/users/:id", userHandler.DeleteUser)
-			admin.POST("/users/:id/activate", userHandler.ActivateUser)
-			admin.POST("/users/:id/deactivate", userHandler.DeactivateUser)
+			admin.GET("/users", middleware.RequirePermission(service.PermissionUsersRead), userHandler.ListUsers)
+			admin.GET("/users/export", middleware.RequirePermission(service.PermissionUsersRead), userHandler.ExportUsers)
+			admin.GET("/users/:id", middleware.RequirePermission(service.PermissionUsersRead), userHandler.GetUser)
+			admin.PUT("/users/:id", middleware.RequirePermission(service.PermissionUsersWrite), userHandler.UpdateUser)
+			admin.DELETE("/users/:id", middleware.RequirePermission(service.PermissionUsersDelete), privacyHandler.RequestErasureForUser)
+			admin.DELETE("/users/:id/erasure", middleware.RequirePermission(service.PermissionUsersDelete), privacyHandler.CancelErasureForUser)
+			admin.GET("/users/:id/data-export", middleware.RequirePermission(service.PermissionUsersRead), privacyHandler.ExportForUser)
+			admin.POST("/users/:id/activate", middleware.RequirePermission(service.PermissionUsersWrite), userHandler.ActivateUser)
+			admin.POST("/users/:id/deactivate", middleware.RequirePermission(service.PermissionUsersWrite), userHandler.DeactivateUser)
+			admin.POST("/users/:id/unlock", middleware.RequirePermission(service.PermissionUsersUnlock), adminHandler.UnlockUser)
+			admin.POST("/users/:id/impersonate", middleware.RequirePermission(service.PermissionUsersImpersonate), impersonationHandler.Impersonate)
+			admin.PUT("/users/:id/role", middleware.RequirePermission(service.PermissionRolesManage), roleHandler.AssignRole)
+			admin.GET("/users/:id/sessions", middleware.RequirePermission(service.PermissionSessionsManage), sessionHandler.ListForUser)
+			admin.DELETE("/users/:id/sessions", middleware.RequirePermission(service.PermissionSessionsManage), sessionHandler.RevokeAllForUser)
+			admin.DELETE("/users/:id/sessions/:sid", middleware.RequirePermission(service.PermissionSessionsManage), sessionHandler.RevokeForUser)
+
+			// Staff invitations
+			admin.POST("/invitations", middleware.RequirePermission(service.PermissionUsersWrite), invitationHandler.Create)
+			admin.GET("/invitations", middleware.RequirePermission(service.PermissionUsersRead), invitationHandler.List)
+			admin.DELETE("/invitations/:id", middleware.RequirePermission(service.PermissionUsersWrite), invitationHandler.Revoke)
+
+			// Bulk user imports
+			admin.POST("/user-imports", middleware.RequirePermission(service.PermissionUsersWrite), userImportHandler.Create)
+			admin.GET("/user-imports", middleware.RequirePermission(service.PermissionUsersRead), userImportHandler.List)
+			admin.GET("/user-imports/:id", middleware.RequirePermission(service.PermissionUsersRead), userImportHandler.Get)
+			admin.GET("/user-imports/:id/errors", middleware.RequirePermission(service.PermissionUsersRead), userImportHandler.ListErrors)
+
+			// Permissions and custom roles
+			admin.GET("/permissions", middleware.RequirePermission(service.PermissionRolesManage), roleHandler.ListPermissions)
+			admin.GET("/roles", middleware.RequirePermission(service.PermissionRolesManage), roleHandler.ListRoles)
+			admin.POST("/roles", middleware.RequirePermission(service.PermissionRolesManage), roleHandler.CreateRole)
+			admin.PUT("/roles/:id", middleware.RequirePermission(service.PermissionRolesManage), roleHandler.UpdateRole)
+			admin.DELETE("/roles/:id", middleware.RequirePermission(service.PermissionRolesManage), roleHandler.DeleteRole)
+
+			// Audit trail
+			admin.GET("/audit-logs", middleware.RequirePermission(service.PermissionAuditRead), auditHandler.List)
+			admin.GET("/audit-logs/export", middleware.RequirePermission(service.PermissionAuditRead), auditHandler.Export)
+
+			// OAuth clients of the tenant, platform-wide ones with ?platform=true
+			admin.POST("/oauth/clients", middleware.RequirePermission(service.PermissionOAuthClientsManage), oauthHandler.CreateClient)
+			admin.GET("/oauth/clients", middleware.RequirePermission(service.PermissionOAuthClientsManage), oauthHandler.ListClients)
+			admin.DELETE("/oauth/clients/:id", middleware.RequirePermission(service.PermissionOAuthClientsManage), oauthHandler.DeleteClient)
+
+			// API keys of tenant integrations
+			admin.POST("/api-keys", middleware.RequirePermission(service.PermissionAPIKeysManage), apiKeyHandler.Create)
+			admin.GET("/api-keys", middleware.RequirePermission(service.PermissionAPIKeysManage), apiKeyHandler.List)
+			admin.DELETE("/api-keys/:id", middleware.RequirePermission(service.PermissionAPIKeysManage), apiKeyHandler.Revoke)
 		}
 	}
 
 	return router
 }
+
+// splitList parses a comma-separated setting, nil when it is empty
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
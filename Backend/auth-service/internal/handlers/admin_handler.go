@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminHandler serves account administration endpoints for tenant admins
+// and super admins
+type AdminHandler struct {
+	authService *service.AuthService
+}
+
+func NewAdminHandler(authService *service.AuthService) *AdminHandler {
+	return &AdminHandler{
+		authService: authService,
+	}
+}
+
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = h.authService.UnlockUser(c.Request.Context(), userID)
+	if err == service.ErrUserNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// APIKeyHandler lets tenant admins manage the API keys of their
+// integrations
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Create issues a key. The response carries the key itself, which is not
+// shown again.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.apiKeyService.Create(c.Request.Context(), tenantID, &req)
+	if err != nil {
+		respondAPIKeyError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.List(c.Request.Context(), tenantID)
+	if err != nil {
+		respondAPIKeyError(c, err, "Failed to list API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(c.Request.Context(), tenantID, id); err != nil {
+		respondAPIKeyError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func respondAPIKeyError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrAPIKeyNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+	case service.ErrInvalidPermission, service.ErrInvalidAllowedIP, service.ErrInvalidAPIKeyExpiry:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrAPIKeyScopeForbidden, service.ErrImpersonationForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AuditHandler serves the audit trail to tenant admins and super admins
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+func (h *AuditHandler) List(c *gin.Context) {
+	filter, ok := auditFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.auditService.List(c.Request.Context(), filter)
+	if err != nil {
+		respondAuditError(c, err, "Failed to list audit log")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// Export streams the matching entries as CSV
+func (h *AuditHandler) Export(c *gin.Context) {
+	filter, ok := auditFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	export := newCSVExport(c, "audit-log.csv", auditCSVHeader)
+	err := h.auditService.Export(c.Request.Context(), filter, func(entry *models.AuditEntry) error {
+		return export.Write(auditCSVRecord(entry))
+	})
+	if err := export.Close(err); err != nil {
+		respondAuditError(c, err, "Failed to export audit log")
+	}
+}
+
+var auditCSVHeader = []string{
+	"created_at", "tenant_id", "actor_id", "impersonator_id", "action", "resource_type", "resource_id",
+	"old_values", "new_values", "ip_address", "user_agent",
+}
+
+func auditCSVRecord(entry *models.AuditEntry) []string {
+	return []string{
+		entry.CreatedAt.UTC().Format(time.RFC3339),
+		optionalUUID(entry.TenantID),
+		optionalUUID(entry.ActorID),
+		optionalUUID(entry.ImpersonatorID),
+		entry.Action,
+		entry.ResourceType,
+		optionalUUID(entry.ResourceID),
+		auditValuesJSON(entry.OldValues),
+		auditValuesJSON(entry.NewValues),
+		entry.IPAddress,
+		entry.UserAgent,
+	}
+}
+
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func auditValuesJSON(values map[string]interface{}) string {
+	if len(values) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// auditFilterFromQuery reads the filter from tenant_id, actor_id, action,
+// resource_type, resource_id, from and to (RFC 3339), limit and offset
+func auditFilterFromQuery(c *gin.Context) (*models.AuditFilter, bool) {
+	filter := &models.AuditFilter{
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+	}
+
+	ids := map[string]**uuid.UUID{
+		"tenant_id":   &filter.TenantID,
+		"actor_id":    &filter.ActorID,
+		"resource_id": &filter.ResourceID,
+	}
+	for param, target := range ids {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return nil, false
+		}
+		*target = &id
+	}
+
+	times := map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	}
+	for param, target := range times {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339"})
+			return nil, false
+		}
+		*target = &t
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return nil, false
+		}
+		filter.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return nil, false
+		}
+		filter.Offset = offset
+	}
+
+	return filter, true
+}
+
+func respondAuditError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrAuditAccessDenied:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
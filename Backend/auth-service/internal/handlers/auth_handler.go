@@ -1,191 +1,150 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
-	"time"
+	"strconv"
 
+	"auth-service/internal/middleware"
 	"auth-service/internal/models"
 	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
 )
 
+// AuthHandler serves registration, password login, token refresh and
+// logout
 type AuthHandler struct {
-	authService service.AuthService
+	authService *service.AuthService
 }
 
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
 	}
 }
 
-type RegisterRequest struct {
-	TenantID string `json:"tenant_id"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	FullName string `json:"full_name"`
-	Phone    string `json:"phone"`
-}
-
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token"`
-}
-
-type TokenResponse struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	User         *UserDTO  `json:"user"`
-}
-
-type UserDTO struct {
-	ID       string `json:"id"`
-	TenantID string `json:"tenant_id"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
-	Phone    string `json:"phone"`
-	Role     string `json:"role"`
-}
-
-func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	// Validation
-	if req.Email == "" || req.Password == "" || req.TenantID == "" {
-		respondError(w, http.StatusBadRequest, "Missing required fields")
+	// The tenant comes from the request host or X-Tenant-ID, see TenantContext
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user := &models.User{
-		Email:    req.Email,
-		FullName: req.FullName,
-		Phone:    req.Phone,
-		Role:     "customer",
+	user, err := h.authService.Register(c.Request.Context(), &req, tenantID)
+	if err == service.ErrUserExists {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
 	}
-
-	if err := h.authService.Register(r.Context(), req.TenantID, user, req.Password); err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+	if service.IsPasswordPolicyError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
+	c.JSON(http.StatusCreated, gin.H{
 		"message": "User registered successfully",
-		"user": UserDTO{
-			ID:       user.ID.String(),
-			TenantID: user.TenantID.String(),
-			Email:    user.Email,
-			FullName: user.FullName,
-			Phone:    user.Phone,
-			Role:     user.Role,
-		},
+		"user":    user,
 	})
 }
 
-func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		respondError(w, http.StatusBadRequest, "Email and password are required")
+	// Requests without a tenant sign in platform-level identities (super
+	// admins), see AuthService.Login
+	tenantID, _ := middleware.GetRequestTenantID(c)
+
+	resp, err := h.authService.Login(c.Request.Context(), &req, tenantID)
+
+	var throttledErr *service.LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		c.Header("Retry-After", strconv.Itoa(int(throttledErr.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": throttledErr.Error()})
 		return
 	}
 
-	accessToken, refreshToken, user, err := h.authService.Login(r.Context(), req.Email, req.Password)
+	// Password was correct, the client continues at /auth/mfa/verify
+	var mfaErr *service.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		c.JSON(http.StatusOK, mfaErr.Challenge)
+		return
+	}
+	// Password was correct but too old, the client continues at
+	// /auth/forgot-password
+	if errors.Is(err, service.ErrPasswordExpired) || err == service.ErrEmailNotVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid credentials")
-		return
-	}
-
-	respondJSON(w, http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
-		User: &UserDTO{
-			ID:       user.ID.String(),
-			TenantID: user.TenantID.String(),
-			Email:    user.Email,
-			FullName: user.FullName,
-			Phone:    user.Phone,
-			Role:     user.Role,
-		},
-	})
-}
-
-func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	var req RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	if req.RefreshToken == "" {
-		respondError(w, http.StatusBadRequest, "Refresh token is required")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
 		return
 	}
 
-	accessToken, newRefreshToken, err := h.authService.RefreshToken(r.Context(), req.RefreshToken)
+	resp, err := h.authService.RefreshAccessToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
-		respondError(w, http.StatusUnauthorized, "Invalid refresh token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
-	respondJSON(w, http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresAt:    time.Now().Add(15 * time.Minute),
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
-func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	refreshToken := r.URL.Query().Get("refresh_token")
-	if refreshToken == "" {
-		respondError(w, http.StatusBadRequest, "Refresh token is required")
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
 		return
 	}
 
-	if err := h.authService.Logout(r.Context(), refreshToken); err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to logout")
+	err := h.authService.Logout(c.Request.Context(), req.RefreshToken)
+	if err == service.ErrInvalidToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
-func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
-	user, ok := r.Context().Value("user").(*models.User)
-	if !ok {
-		respondError(w, http.StatusUnauthorized, "Unauthorized")
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	respondJSON(w, http.StatusOK, UserDTO{
-		ID:       user.ID.String(),
-		TenantID: user.TenantID.String(),
-		Email:    user.Email,
-		FullName: user.FullName,
-		Phone:    user.Phone,
-		Role:     user.Role,
-	})
-}
-
-func respondJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
-}
+	if err := h.authService.LogoutAll(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, map[string]string{"error": message})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
 }
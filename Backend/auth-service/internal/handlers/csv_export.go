@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csvExport streams rows as a CSV attachment. The response starts with the
+// first row, so that access errors of the query still get a proper status.
+type csvExport struct {
+	c        *gin.Context
+	w        *csv.Writer
+	filename string
+	header   []string
+	started  bool
+}
+
+func newCSVExport(c *gin.Context, filename string, header []string) *csvExport {
+	return &csvExport{
+		c:        c,
+		w:        csv.NewWriter(c.Writer),
+		filename: filename,
+		header:   header,
+	}
+}
+
+func (e *csvExport) start() error {
+	e.started = true
+	e.c.Header("Content-Type", "text/csv; charset=utf-8")
+	e.c.Header("Content-Disposition", `attachment; filename="`+e.filename+`"`)
+	e.c.Status(http.StatusOK)
+	return e.w.Write(e.header)
+}
+
+// Write sends one row, cells escaped with csvCell
+func (e *csvExport) Write(record []string) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	cells := make([]string, len(record))
+	for i, value := range record {
+		cells[i] = csvCell(value)
+	}
+	if err := e.w.Write(cells); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Close ends the export once the rows were written or err stopped it. An
+// error before the first row is returned for the caller to respond with;
+// later the headers are already sent and the file is cut short.
+func (e *csvExport) Close(err error) error {
+	switch {
+	case err != nil && !e.started:
+		return err
+	case err != nil:
+		log.Printf("Export of %s aborted: %v", e.filename, err)
+	case !e.started:
+		e.start()
+	}
+	e.w.Flush()
+	return nil
+}
+
+// csvCell keeps spreadsheets from evaluating a value as a formula. Exports
+// contain user-controlled text, such as names and the user agents of failed
+// logins.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForwardAuthHandler answers the gateway's forward-auth subrequests. The
+// gateway must drop these headers from incoming requests and copy them
+// from a successful response onto the upstream request.
+type ForwardAuthHandler struct {
+	forwardAuthService *service.ForwardAuthService
+}
+
+func NewForwardAuthHandler(forwardAuthService *service.ForwardAuthService) *ForwardAuthHandler {
+	return &ForwardAuthHandler{
+		forwardAuthService: forwardAuthService,
+	}
+}
+
+// Verify checks the Authorization header of the original request and
+// returns the caller in X-User-ID, X-Tenant-ID, X-Role and X-Permissions,
+// plus X-Client-ID, X-API-Key-ID or X-Actor-ID where they apply
+func (h *ForwardAuthHandler) Verify(c *gin.Context) {
+	identity, err := h.forwardAuthService.Verify(c.Request.Context(), c.GetHeader("Authorization"), c.ClientIP())
+	if err != nil {
+		respondForwardAuthError(c, err)
+		return
+	}
+
+	setHeader := func(name, value string) {
+		if value != "" {
+			c.Header(name, value)
+		}
+	}
+	setHeader("X-User-ID", identity.UserID)
+	setHeader("X-Tenant-ID", identity.TenantID)
+	setHeader("X-Role", identity.Role)
+	setHeader("X-Permissions", strings.Join(identity.Permissions, ","))
+	setHeader("X-Client-ID", identity.ClientID)
+	setHeader("X-API-Key-ID", identity.APIKeyID)
+	setHeader("X-Actor-ID", identity.ActorID)
+	c.Header("Cache-Control", "no-store")
+
+	c.Status(http.StatusOK)
+}
+
+func respondForwardAuthError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrInvalidToken, service.ErrTokenRevoked, service.ErrInvalidAPIKey:
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case service.ErrAPIKeyIPNotAllowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify request"})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImpersonationHandler lets support staff act as a user
+type ImpersonationHandler struct {
+	impersonationService *service.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService *service.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+	}
+}
+
+// Impersonate returns a short-lived access token acting as the user. The
+// reason is required and kept in the audit trail.
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	resp, err := h.impersonationService.Impersonate(c.Request.Context(), userID, req.Reason)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, resp)
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrCannotImpersonate, service.ErrImpersonationForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to impersonate user"})
+	}
+}
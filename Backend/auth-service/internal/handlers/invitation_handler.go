@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InvitationHandler serves staff invitations: admins create, list and
+// revoke them, invitees look them up and accept them
+type InvitationHandler struct {
+	invitationService *service.InvitationService
+}
+
+func NewInvitationHandler(invitationService *service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+	}
+}
+
+func (h *InvitationHandler) Create(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.invitationService.Create(c.Request.Context(), tenantID, &req)
+	if err != nil {
+		respondInvitationError(c, err, "Failed to create invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// List returns the invitations of the tenant, filtered by ?status=
+func (h *InvitationHandler) List(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitationService.List(c.Request.Context(), tenantID, c.Query("status"))
+	if err != nil {
+		respondInvitationError(c, err, "Failed to list invitations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func (h *InvitationHandler) Revoke(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := h.invitationService.Revoke(c.Request.Context(), tenantID, id); err != nil {
+		respondInvitationError(c, err, "Failed to revoke invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// Lookup shows the invitee what the link in ?token= invites them to
+func (h *InvitationHandler) Lookup(c *gin.Context) {
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.invitationService.Lookup(c.Request.Context(), tenantID, c.Query("token"))
+	if err != nil {
+		respondInvitationError(c, err, "Failed to get invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":      invitation.Email,
+		"phone":      invitation.Phone,
+		"role":       invitation.Role,
+		"pvz_id":     invitation.PVZID,
+		"expires_at": invitation.ExpiresAt,
+	})
+}
+
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.invitationService.Accept(c.Request.Context(), tenantID, &req)
+	if err != nil {
+		respondInvitationError(c, err, "Failed to accept invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func respondInvitationError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrInvitationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case service.ErrInvalidInvitation:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrPasswordTooShort, service.ErrPasswordTooSimple, service.ErrPasswordReused, service.ErrPasswordBreached:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrInvitationContactRequired, service.ErrInvalidPhone, service.ErrRoleNotAssignable,
+		service.ErrPVZNotFound, service.ErrPVZScopeNotAllowed:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrInvitationRoleForbidden, service.ErrPermissionNotHeld:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrInvitationUserExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
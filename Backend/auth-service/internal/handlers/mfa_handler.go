@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler serves TOTP management for signed-in users and the second step
+// of the two-step login
+type MFAHandler struct {
+	mfaService  *service.MFAService
+	authService *service.AuthService
+}
+
+func NewMFAHandler(mfaService *service.MFAService, authService *service.AuthService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		authService: authService,
+	}
+}
+
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	resp, err := h.mfaService.BeginEnrollment(c.Request.Context(), userID)
+	if err != nil {
+		respondMFAError(c, err, "Failed to start enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmEnrollment(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.mfaService.Disable(c.Request.Context(), userID, req.Code); err != nil {
+		respondMFAError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// LoginEnroll starts the mandatory enrollment of a user who was stopped at
+// login by the tenant's 2FA policy
+func (h *MFAHandler) LoginEnroll(c *gin.Context) {
+	var req models.MFALoginEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	resp, err := h.mfaService.BeginChallengeEnrollment(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		respondMFAError(c, err, "Failed to start enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// LoginVerify exchanges an MFA token and a TOTP or recovery code for tokens
+func (h *MFAHandler) LoginVerify(c *gin.Context) {
+	var req models.MFALoginVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code or recovery code is required"})
+		return
+	}
+
+	resp, err := h.authService.CompleteMFALogin(c.Request.Context(), &req)
+	var throttledErr *service.LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		c.Header("Retry-After", strconv.Itoa(int(throttledErr.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": throttledErr.Error()})
+		return
+	}
+	if err != nil {
+		respondMFAError(c, err, "Failed to verify two-factor code")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrInvalidMFACode, service.ErrInvalidMFAChallenge:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case service.ErrMFANotAvailable, service.ErrImpersonationForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrMFAAlreadyEnabled, service.ErrMFANotEnabled, service.ErrMFANotEnrolled, service.ErrMFAEnrollmentPending:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OAuthHandler serves the OAuth 2.0 / OpenID Connect endpoints and the
+// registration of tenant and platform-wide clients
+type OAuthHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOAuthHandler(oidcService *service.OIDCService) *OAuthHandler {
+	return &OAuthHandler{
+		oidcService: oidcService,
+	}
+}
+
+// Authorize validates the request and sends the browser to the hosted
+// login page
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req models.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	loginURL, err := h.oidcService.Authorize(c.Request.Context(), &req)
+
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		if redirectURL := oauthErr.RedirectURL(); redirectURL != "" {
+			c.Redirect(http.StatusFound, redirectURL)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, loginURL)
+}
+
+// ApproveAuthorization is called by the hosted login page with the signed-in
+// user's access token and returns where to send the browser next
+func (h *OAuthHandler) ApproveAuthorization(c *gin.Context) {
+	var req models.ApproveAuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	redirectURL, err := h.oidcService.ApproveAuthorization(c.Request.Context(), req.RequestID)
+
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		if errorURL := oauthErr.RedirectURL(); errorURL != "" {
+			c.JSON(http.StatusOK, gin.H{"redirect_to": errorURL})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve authorization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectURL})
+}
+
+func (h *OAuthHandler) Token(c *gin.Context) {
+	var req models.OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	// client_secret_basic takes precedence over client_secret_post
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	resp, err := h.oidcService.Token(c.Request.Context(), &req)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		status := http.StatusBadRequest
+		if oauthErr.Code == "invalid_client" {
+			status = http.StatusUnauthorized
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Introspect implements RFC 7662. Client credentials come from HTTP Basic
+// auth or the form body, like at the token endpoint.
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	var req models.IntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	resp, err := h.oidcService.Introspect(c.Request.Context(), &req)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	info, err := h.oidcService.UserInfo(c.Request.Context())
+	if err == service.ErrUserNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, ok := clientTenantID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.oidcService.RegisterClient(c.Request.Context(), tenantID, &req)
+
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Description})
+		return
+	}
+	if err == service.ErrOAuthClientNotFound {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if err == service.ErrImpersonationForbidden {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	tenantID, ok := clientTenantID(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.oidcService.ListClients(c.Request.Context(), tenantID)
+	if err == service.ErrOAuthClientNotFound {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	tenantID, ok := clientTenantID(c)
+	if !ok {
+		return
+	}
+
+	err = h.oidcService.DeleteClient(c.Request.Context(), tenantID, id)
+	if err == service.ErrOAuthClientNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted"})
+}
+
+// clientTenantID is adminTenantID, or uuid.Nil for the platform-wide
+// machine clients addressed with ?platform=true
+func clientTenantID(c *gin.Context) (uuid.UUID, bool) {
+	if c.Query("platform") == "true" {
+		return uuid.Nil, true
+	}
+	return adminTenantID(c)
+}
+
+// adminTenantID returns the tenant an admin request operates on: the
+// tenant_id query parameter for super admins, otherwise the caller's tenant
+func adminTenantID(c *gin.Context) (uuid.UUID, bool) {
+	if raw := c.Query("tenant_id"); raw != "" {
+		tenantID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+			return uuid.Nil, false
+		}
+		return tenantID, true
+	}
+
+	tenantID, err := middleware.GetTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
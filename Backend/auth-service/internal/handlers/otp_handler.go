@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OTPHandler serves passwordless login with SMS codes
+type OTPHandler struct {
+	otpService *service.PhoneOTPService
+}
+
+func NewOTPHandler(otpService *service.PhoneOTPService) *OTPHandler {
+	return &OTPHandler{
+		otpService: otpService,
+	}
+}
+
+func (h *OTPHandler) RequestCode(c *gin.Context) {
+	var req models.PhoneOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.otpService.RequestCode(c.Request.Context(), tenantID, req.Phone)
+
+	var rateLimitErr *service.OTPRateLimitError
+	if errors.As(err, &rateLimitErr) {
+		c.Header("Retry-After", strconv.Itoa(int(rateLimitErr.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": rateLimitErr.Error()})
+		return
+	}
+	if err == service.ErrInvalidPhone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code sent"})
+}
+
+func (h *OTPHandler) VerifyCode(c *gin.Context) {
+	var req models.PhoneOTPVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.otpService.Verify(c.Request.Context(), tenantID, req.Phone, req.Code)
+
+	// Staff accounts continue at /auth/mfa/verify
+	var mfaErr *service.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		c.JSON(http.StatusOK, mfaErr.Challenge)
+		return
+	}
+	if err == service.ErrInvalidPhone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err == service.ErrInvalidOTP {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	resetService *service.PasswordResetService
+}
+
+func NewPasswordHandler(resetService *service.PasswordResetService) *PasswordHandler {
+	return &PasswordHandler{
+		resetService: resetService,
+	}
+}
+
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.resetService.RequestReset(c.Request.Context(), tenantID, req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	// Same response whether or not the account exists
+	c.JSON(http.StatusOK, gin.H{"message": "If an account with this email exists, a reset link has been sent"})
+}
+
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.resetService.ResetPassword(c.Request.Context(), tenantID, req.Token, req.Password)
+	if err == service.ErrInvalidResetToken || service.IsPasswordPolicyError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PrivacyHandler serves personal data exports and erasure requests, for the
+// signed-in user and for admins acting on behalf of their users
+type PrivacyHandler struct {
+	privacyService *service.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+	}
+}
+
+func (h *PrivacyHandler) Export(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	export, err := h.privacyService.Export(c.Request.Context(), userID)
+	if err != nil {
+		respondPrivacyError(c, err, "Failed to export personal data")
+		return
+	}
+
+	writeDataExport(c, export)
+}
+
+func (h *PrivacyHandler) ExportForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	export, err := h.privacyService.ExportManagedUser(c.Request.Context(), userID)
+	if err != nil {
+		respondPrivacyError(c, err, "Failed to export personal data")
+		return
+	}
+
+	writeDataExport(c, export)
+}
+
+func (h *PrivacyHandler) RequestErasure(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	erasure, err := h.privacyService.RequestErasure(c.Request.Context(), userID)
+	if err != nil {
+		respondPrivacyError(c, err, "Failed to request erasure")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Personal data will be erased at the end of the grace period",
+		"erasure": erasure,
+	})
+}
+
+func (h *PrivacyHandler) RequestErasureForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	erasure, err := h.privacyService.RequestErasureForManagedUser(c.Request.Context(), userID)
+	if err != nil {
+		respondPrivacyError(c, err, "Failed to request erasure")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Personal data will be erased at the end of the grace period",
+		"erasure": erasure,
+	})
+}
+
+func (h *PrivacyHandler) CancelErasure(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.privacyService.CancelErasure(c.Request.Context(), userID); err != nil {
+		respondPrivacyError(c, err, "Failed to cancel erasure")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Erasure cancelled"})
+}
+
+func (h *PrivacyHandler) CancelErasureForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.privacyService.CancelErasureForManagedUser(c.Request.Context(), userID); err != nil {
+		respondPrivacyError(c, err, "Failed to cancel erasure")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Erasure cancelled"})
+}
+
+func writeDataExport(c *gin.Context, export *models.DataExport) {
+	filename := fmt.Sprintf("personal-data-%s.zip", export.User.ID)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if err := service.WriteDataExportArchive(c.Writer, export); err != nil {
+		// Headers are already sent, the archive is cut short
+		log.Printf("Personal data export aborted: %v", err)
+	}
+}
+
+func respondPrivacyError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrImpersonationForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrErasurePending, service.ErrNoErasurePending, service.ErrAccountAnonymized:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
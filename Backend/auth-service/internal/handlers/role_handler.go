@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RoleHandler serves the permission catalogue and the tenant's custom roles
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	builtin := gin.H{}
+	for _, role := range []string{"super_admin", "tenant_admin", "vendor", "operator", "customer"} {
+		builtin[role] = service.RolePermissions(role)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"permissions":   service.TenantPermissions(),
+		"builtin_roles": builtin,
+	})
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roleService.ListRoles(c.Request.Context(), tenantID)
+	if err != nil {
+		respondRoleError(c, err, "Failed to list roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.roleService.CreateRole(c.Request.Context(), tenantID, &req)
+	if err != nil {
+		respondRoleError(c, err, "Failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(c.Request.Context(), tenantID, id, &req)
+	if err != nil {
+		respondRoleError(c, err, "Failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.roleService.DeleteRole(c.Request.Context(), tenantID, id); err != nil {
+		respondRoleError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.roleService.AssignRole(c.Request.Context(), userID, req.RoleID); err != nil {
+		respondRoleError(c, err, "Failed to assign role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+func respondRoleError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrRoleNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrRoleNameReserved, service.ErrInvalidPermission:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrRoleNameTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case service.ErrImpersonationForbidden, service.ErrPermissionNotHeld:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionHandler lets users see and end their login sessions, and admins do
+// the same for users of their tenant
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) ListMine(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.sessionService.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		respondSessionError(c, err, "Failed to list sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *SessionHandler) RevokeMine(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.sessionService.Revoke(c.Request.Context(), userID, c.Param("id")); err != nil {
+		respondSessionError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+func (h *SessionHandler) ListForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	sessions, err := h.sessionService.ListForManagedUser(c.Request.Context(), userID)
+	if err != nil {
+		respondSessionError(c, err, "Failed to list sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *SessionHandler) RevokeForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.sessionService.RevokeForManagedUser(c.Request.Context(), userID, c.Param("sid")); err != nil {
+		respondSessionError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+func (h *SessionHandler) RevokeAllForUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.sessionService.RevokeAllForManagedUser(c.Request.Context(), userID); err != nil {
+		respondSessionError(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+func respondSessionError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrSessionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UserHandler serves the signed-in user's profile and user administration
+// for tenant admins and super admins
+type UserHandler struct {
+	userService *service.UserService
+}
+
+func NewUserHandler(userService *service.UserService) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+	}
+}
+
+func (h *UserHandler) GetCurrentUser(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.userService.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		respondUserError(c, err, "Failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) UpdateCurrentUser(c *gin.Context) {
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		respondUserError(c, err, "Failed to update user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, &req); err != nil {
+		respondUserError(c, err, "Failed to change password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// ListUsers returns a page of users. Pass next_cursor back as cursor for
+// the following page.
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	filter, ok := userFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	users, next, err := h.userService.SearchUsers(c.Request.Context(), filter)
+	if err != nil {
+		respondUserError(c, err, "Failed to list users")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":       users,
+		"limit":       filter.Limit,
+		"next_cursor": next,
+	})
+}
+
+// ExportUsers streams every matching user as CSV
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	filter, ok := userFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	export := newCSVExport(c, "users.csv", userCSVHeader)
+	err := h.userService.ExportUsers(c.Request.Context(), filter, func(user *models.User) error {
+		return export.Write(userCSVRecord(user))
+	})
+	if err := export.Close(err); err != nil {
+		respondUserError(c, err, "Failed to export users")
+	}
+}
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		respondUserError(c, err, "Failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.AdminUpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), userID, &req)
+	if err != nil {
+		respondUserError(c, err, "Failed to update user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) ActivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *UserHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *UserHandler) setActive(c *gin.Context, active bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userService.SetActive(c.Request.Context(), userID, active)
+	if err != nil {
+		respondUserError(c, err, "Failed to update user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+var userCSVHeader = []string{
+	"id", "email", "phone", "first_name", "last_name", "role",
+	"is_verified", "is_active", "last_login_at", "created_at",
+}
+
+func userCSVRecord(user *models.User) []string {
+	lastLogin := ""
+	if user.LastLoginAt != nil {
+		lastLogin = user.LastLoginAt.UTC().Format(time.RFC3339)
+	}
+
+	return []string{
+		user.ID.String(),
+		user.Email,
+		optionalString(user.Phone),
+		optionalString(user.FirstName),
+		optionalString(user.LastName),
+		user.Role,
+		strconv.FormatBool(user.IsVerified),
+		strconv.FormatBool(user.IsActive),
+		lastLogin,
+		user.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func optionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// userFilterFromQuery reads the filter from tenant_id, role, is_active,
+// is_verified, created_from, created_to, last_login_from, last_login_to
+// (RFC 3339), q, cursor and limit
+func userFilterFromQuery(c *gin.Context) (*models.UserFilter, bool) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return nil, false
+	}
+
+	filter := &models.UserFilter{
+		TenantID: tenantID,
+		Role:     c.Query("role"),
+		Query:    c.Query("q"),
+	}
+
+	flags := map[string]**bool{
+		"is_active":   &filter.IsActive,
+		"is_verified": &filter.IsVerified,
+	}
+	for param, target := range flags {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return nil, false
+		}
+		*target = &value
+	}
+
+	times := map[string]**time.Time{
+		"created_from":    &filter.CreatedFrom,
+		"created_to":      &filter.CreatedTo,
+		"last_login_from": &filter.LastLoginFrom,
+		"last_login_to":   &filter.LastLoginTo,
+	}
+	for param, target := range times {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339"})
+			return nil, false
+		}
+		*target = &t
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := service.ParseUserCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return nil, false
+		}
+		filter.After = cursor
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return nil, false
+		}
+		filter.Limit = limit
+	}
+
+	return filter, true
+}
+
+func respondUserError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrInvalidCurrentPassword:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrPasswordTooShort, service.ErrPasswordTooSimple, service.ErrPasswordReused, service.ErrPasswordBreached:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrRoleNotAssignable:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrRoleChangeForbidden, service.ErrPermissionNotHeld, service.ErrImpersonationForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrInvalidCursor:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
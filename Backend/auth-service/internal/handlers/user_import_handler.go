@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultImportErrorPageSize = 100
+	maxImportErrorPageSize     = 1000
+)
+
+// UserImportHandler serves bulk user imports to tenant admins and super
+// admins
+type UserImportHandler struct {
+	importService *service.UserImportService
+}
+
+func NewUserImportHandler(importService *service.UserImportService) *UserImportHandler {
+	return &UserImportHandler{
+		importService: importService,
+	}
+}
+
+// Create queues the uploaded file for import. It takes a multipart form
+// with the file, its format (csv or jsonl, taken from the file name when
+// omitted) and send_invitations.
+func (h *UserImportHandler) Create(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+		return
+	}
+	if header.Size > service.MaxUserImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = importFormatFromName(header.Filename)
+	}
+
+	sendInvitations := false
+	if raw := c.PostForm("send_invitations"); raw != "" {
+		sendInvitations, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid send_invitations"})
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, service.MaxUserImportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	job, err := h.importService.CreateJob(c.Request.Context(), tenantID, format, sendInvitations, data)
+	if err != nil {
+		respondImportError(c, err, "Failed to create import")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *UserImportHandler) List(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.importService.ListJobs(c.Request.Context(), tenantID)
+	if err != nil {
+		respondImportError(c, err, "Failed to list imports")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imports": jobs})
+}
+
+func (h *UserImportHandler) Get(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	job, err := h.importService.GetJob(c.Request.Context(), tenantID, jobID)
+	if err != nil {
+		respondImportError(c, err, "Failed to get import")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ListErrors returns the rejected rows of an import, ordered by row
+func (h *UserImportHandler) ListErrors(c *gin.Context) {
+	tenantID, ok := adminTenantID(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultImportErrorPageSize)))
+	if err != nil || limit <= 0 || limit > maxImportErrorPageSize {
+		limit = defaultImportErrorPageSize
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	rows, err := h.importService.ListErrors(c.Request.Context(), tenantID, jobID, limit, offset)
+	if err != nil {
+		respondImportError(c, err, "Failed to list import errors")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"errors": rows,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func importFormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return service.UserImportFormatCSV
+	case ".jsonl", ".ndjson":
+		return service.UserImportFormatJSONL
+	default:
+		return ""
+	}
+}
+
+func respondImportError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+	case errors.Is(err, service.ErrInvalidImportFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrImportRoleForbidden), errors.Is(err, service.ErrPermissionNotHeld):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
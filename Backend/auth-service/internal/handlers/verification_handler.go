@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"auth-service/internal/middleware"
+	"auth-service/internal/models"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerificationHandler struct {
+	verificationService *service.EmailVerificationService
+}
+
+func NewVerificationHandler(verificationService *service.EmailVerificationService) *VerificationHandler {
+	return &VerificationHandler{
+		verificationService: verificationService,
+	}
+}
+
+func (h *VerificationHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	user, err := h.verificationService.Verify(c.Request.Context(), req.Token)
+	if err == service.ErrInvalidVerificationToken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+		"user":    user,
+	})
+}
+
+func (h *VerificationHandler) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tenantID, err := middleware.GetRequestTenantID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.verificationService.Resend(c.Request.Context(), tenantID, req.Email)
+
+	var cooldownErr *service.VerificationCooldownError
+	if errors.As(err, &cooldownErr) {
+		c.Header("Retry-After", strconv.Itoa(int(cooldownErr.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": cooldownErr.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is not verified, a new link has been sent"})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"auth-service/internal/config"
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WellKnownHandler serves the public key set and the OpenID discovery
+// document so other services can verify tokens without a shared secret
+type WellKnownHandler struct {
+	keys *service.KeyManager
+	cfg  *config.Config
+}
+
+func NewWellKnownHandler(keys *service.KeyManager, cfg *config.Config) *WellKnownHandler {
+	return &WellKnownHandler{
+		keys: keys,
+		cfg:  cfg,
+	}
+}
+
+func (h *WellKnownHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+func (h *WellKnownHandler) OpenIDConfiguration(c *gin.Context) {
+	issuer := strings.TrimSuffix(h.cfg.Issuer, "/")
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"authorization_endpoint":                issuer + "/api/v1/oauth/authorize",
+		"token_endpoint":                        issuer + "/api/v1/oauth/token",
+		"userinfo_endpoint":                     issuer + "/api/v1/oauth/userinfo",
+		"introspection_endpoint":                issuer + "/api/v1/oauth/introspect",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{service.GrantTypeAuthorizationCode, service.GrantTypeRefreshToken, service.GrantTypeClientCredentials},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      service.SupportedScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": h.keys.SupportedAlgorithms(),
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "jti",
+			"user_id", "tenant_id", "role", "email", "email_verified", "phone_number",
+			"name", "given_name", "family_name",
+		},
+	})
+}
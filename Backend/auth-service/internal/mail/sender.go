@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"auth-service/internal/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers transactional emails
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSender picks the sender implementation configured by MAIL_DRIVER
+func NewSender(cfg *config.Config) (Sender, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "file":
+		return NewFileSender(cfg.MailOutputDir, cfg.MailFrom)
+	case "log", "":
+		return NewLogSender(cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.MailDriver)
+	}
+}
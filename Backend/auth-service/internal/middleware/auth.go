@@ -1,17 +1,20 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"auth-service/internal/database"
 	"auth-service/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// AuthMiddleware validates JWT token and sets user context
-func AuthMiddleware(authService *service.AuthService) gin.HandlerFunc {
+// AuthMiddleware validates the bearer JWT, or the tenant API key of
+// "Authorization: ApiKey <key>", and sets the caller context
+func AuthMiddleware(authService *service.AuthService, apiKeyService *service.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -21,29 +24,89 @@ func AuthMiddleware(authService *service.AuthService) gin.HandlerFunc {
 		}
 
 		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		if len(tokenParts) != 2 || (tokenParts[0] != "Bearer" && tokenParts[0] != "ApiKey") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
 		}
 
-		token := tokenParts[1]
-		claims, err := authService.ValidateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
+		var claims *service.Claims
+		var err error
+		if tokenParts[0] == "ApiKey" {
+			claims, err = apiKeyService.Authenticate(c.Request.Context(), tokenParts[1], c.ClientIP())
+			if err == service.ErrAPIKeyIPNotAllowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+				c.Abort()
+				return
+			}
+		} else {
+			claims, err = authService.VerifyAccessToken(c.Request.Context(), tokenParts[1])
+			if err == service.ErrInvalidToken {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
 		}
 
-		// Set user context
+		// Set user context. Service tokens and API keys have no user,
+		// their scopes come through as permissions.
 		c.Set("user_id", claims.UserID)
 		c.Set("tenant_id", claims.TenantID)
 		c.Set("role", claims.Role)
+		c.Set("permissions", claims.EffectivePermissions())
+		if claims.IsService() {
+			c.Set("client_id", claims.ClientID)
+		}
+		if claims.IsAPIKey() {
+			c.Set("api_key_id", claims.APIKeyID)
+		}
+		// Impersonation tokens act as user_id on behalf of actor_id
+		if claims.IsImpersonated() {
+			c.Set("actor_id", claims.Act.Subject)
+		}
+
+		// Expose claims to services via the request context
+		ctx := service.ContextWithClaims(c.Request.Context(), claims)
+
+		// Scope queries for row-level security: super admins and
+		// platform-wide services work across tenants, everyone else only
+		// sees their own tenant's rows
+		if claims.IsPlatform() {
+			ctx = database.WithPlatformScope(ctx)
+		} else {
+			tenantID, err := uuid.Parse(claims.TenantID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			ctx = database.WithTenant(ctx, tenantID)
+		}
+		c.Request = c.Request.WithContext(ctx)
 
-		// Set tenant context for RLS
-		c.Set("app.current_tenant", claims.TenantID)
+		c.Next()
+	}
+}
 
+// AuditImpersonation records every request made with an impersonation
+// token once it has been handled. Must run after AuthMiddleware.
+func AuditImpersonation(impersonationService *service.ImpersonationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		c.Next()
+
+		if _, impersonated := c.Get("actor_id"); impersonated {
+			impersonationService.RecordRequest(c.Request.Context(), c.Request.Method, c.FullPath(), c.Writer.Status())
+		}
 	}
 }
 
@@ -70,32 +133,148 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	}
 }
 
-// TenantContext extracts tenant from subdomain or custom domain
-func TenantContext() gin.HandlerFunc {
+// RequirePermission allows the request only if the caller holds every
+// listed permission. Must run after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		host := c.Request.Host
-		
-		// Try to extract tenant from subdomain
-		// Example: shop1.platform.com -> shop1
-		parts := strings.Split(host, ".")
-		if len(parts) >= 2 {
-			subdomain := parts[0]
-			c.Set("tenant_subdomain", subdomain)
+		value, exists := c.Get("permissions")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User permissions not found"})
+			c.Abort()
+			return
 		}
 
-		// Custom domain handling would be done here
-		// by checking database for custom_domain match
+		granted := value.([]string)
+		for _, permission := range permissions {
+			if !service.HasPermission(granted, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "required": permission})
+				c.Abort()
+				return
+			}
+		}
 
 		c.Next()
 	}
 }
 
+// FirstPartyOnly rejects tokens issued to OAuth clients, for endpoints
+// only the platform's own apps may call on behalf of a user. Must run after
+// AuthMiddleware.
+func FirstPartyOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := service.ClaimsFromContext(c.Request.Context())
+		if !ok || claims.IsClientBound() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope allows only service tokens and API keys granted every
+// listed scope, for endpoints meant for machine clients and integrations.
+// Must run after AuthMiddleware.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := service.ClaimsFromContext(c.Request.Context())
+		if !ok || (!claims.IsService() && !claims.IsAPIKey()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Service token required"})
+			c.Abort()
+			return
+		}
+
+		granted := strings.Fields(claims.Scope)
+		for _, scope := range scopes {
+			if !service.HasPermission(granted, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "required": scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// TenantContext resolves the tenant from the subdomain or custom domain of
+// the request, or from the X-Tenant-ID header of API clients. Requests for
+// unknown or suspended tenants are rejected; requests to the platform's own
+// hosts continue without a tenant.
+func TenantContext(resolver *service.TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		// Example: shop1.platform.com -> shop1, or shop.example.com by custom_domain
+		tenant, err := resolver.ResolveHost(ctx, c.Request.Host)
+		if err != nil {
+			abortTenantError(c, err)
+			return
+		}
+
+		// Explicit tenant from API clients, which must agree with the host
+		if raw := c.GetHeader("X-Tenant-ID"); raw != "" {
+			tenantID, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid X-Tenant-ID header"})
+				c.Abort()
+				return
+			}
+
+			if tenant == nil {
+				tenant, err = resolver.ResolveID(ctx, tenantID)
+				if err != nil {
+					abortTenantError(c, err)
+					return
+				}
+			} else if tenant.ID != tenantID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID does not match the requested domain"})
+				c.Abort()
+				return
+			}
+		}
+
+		if tenant != nil {
+			c.Set("request_tenant_id", tenant.ID)
+			c.Set("tenant_subdomain", tenant.Subdomain)
+			c.Set("tenant_tier", tenant.Tier)
+			c.Set("tenant_status", tenant.Status)
+			ctx = service.ContextWithTenant(ctx, tenant)
+			c.Request = c.Request.WithContext(database.WithTenant(ctx, tenant.ID))
+		}
+
+		c.Next()
+	}
+}
+
+func abortTenantError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrTenantNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown tenant"})
+	case service.ErrTenantSuspended:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tenant is suspended"})
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to resolve tenant"})
+	}
+	c.Abort()
+}
+
+// GetRequestTenantID returns the tenant the request is addressed to
+func GetRequestTenantID(c *gin.Context) (uuid.UUID, error) {
+	tenantID, exists := c.Get("request_tenant_id")
+	if !exists {
+		return uuid.Nil, errors.New("tenant context is required")
+	}
+	return tenantID.(uuid.UUID), nil
+}
+
 // CORS middleware
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Tenant-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Tenant-ID, X-Device-Name")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -116,6 +295,17 @@ func GetUserID(c *gin.Context) (uuid.UUID, error) {
 	return uuid.Parse(userIDStr.(string))
 }
 
+// GetActorID returns the operator behind an impersonation token, and false
+// for requests made by the user themselves
+func GetActorID(c *gin.Context) (uuid.UUID, bool) {
+	actorID, exists := c.Get("actor_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(actorID.(string))
+	return id, err == nil
+}
+
 // GetTenantID helper function
 func GetTenantID(c *gin.Context) (uuid.UUID, error) {
 	tenantIDStr, exists := c.Get("tenant_id")
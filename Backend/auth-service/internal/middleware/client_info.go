@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"auth-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDeviceNameLength = 100
+
+// ClientInfo makes the client IP, user agent and device name available to
+// services. The IP is read from X-Forwarded-For only when the request comes
+// from one of TRUSTED_PROXIES, so clients can't rotate it to get around
+// the per-IP login lockout.
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deviceName := c.GetHeader("X-Device-Name")
+		if len(deviceName) > maxDeviceNameLength {
+			deviceName = deviceName[:maxDeviceNameLength]
+		}
+
+		info := &service.ClientInfo{
+			IP:         c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			DeviceName: deviceName,
+		}
+		c.Request = c.Request.WithContext(service.ContextWithClientInfo(c.Request.Context(), info))
+
+		c.Next()
+	}
+}
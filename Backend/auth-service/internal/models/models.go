@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,7 @@ type User struct {
 	LastName     *string    `json:"last_name,omitempty" db:"last_name"`
 	AvatarURL    *string    `json:"avatar_url,omitempty" db:"avatar_url"`
 	Role         string     `json:"role" db:"role"`
+	PVZID        *uuid.UUID `json:"pvz_id,omitempty" db:"pvz_id"` // pickup point staff are limited to
 	IsVerified   bool       `json:"is_verified" db:"is_verified"`
 	IsActive     bool       `json:"is_active" db:"is_active"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
@@ -24,6 +26,36 @@ type User struct {
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// IsPlatformUser reports whether the user is a platform-level identity
+// (super admin) that belongs to no tenant
+func (u *User) IsPlatformUser() bool {
+	return u.TenantID == uuid.Nil
+}
+
+// UserFilter selects the users of a tenant for admin search. Query matches
+// substrings of the email, phone and full name. Results are ordered newest
+// first; After continues from the last user of the previous page, a zero
+// Limit returns all matching users.
+type UserFilter struct {
+	TenantID      uuid.UUID
+	Role          string
+	IsActive      *bool
+	IsVerified    *bool
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	LastLoginFrom *time.Time
+	LastLoginTo   *time.Time
+	Query         string
+	After         *UserCursor
+	Limit         int
+}
+
+// UserCursor is the position of a user in search results
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
 type Tenant struct {
 	ID           uuid.UUID  `json:"id" db:"id"`
 	Subdomain    string     `json:"subdomain" db:"subdomain"`
@@ -38,6 +70,242 @@ type Tenant struct {
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+type UserMFA struct {
+	UserID        uuid.UUID  `json:"user_id" db:"user_id"`
+	TenantID      uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	TOTPSecret    string     `json:"-" db:"totp_secret"` // encrypted
+	IsEnabled     bool       `json:"is_enabled" db:"is_enabled"`
+	RecoveryCodes []string   `json:"-" db:"recovery_codes"` // hashed
+	EnabledAt     *time.Time `json:"enabled_at,omitempty" db:"enabled_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type OAuthClient struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	TenantID         uuid.UUID `json:"tenant_id" db:"tenant_id"` // uuid.Nil for platform-wide clients
+	ClientID         string    `json:"client_id" db:"client_id"`
+	ClientSecretHash *string   `json:"-" db:"client_secret_hash"`
+	Name             string    `json:"name" db:"name"`
+	RedirectURIs     []string  `json:"redirect_uris" db:"redirect_uris"`
+	AllowedScopes    []string  `json:"allowed_scopes" db:"allowed_scopes"`
+	GrantTypes       []string  `json:"grant_types" db:"grant_types"`
+	IsPublic         bool      `json:"is_public" db:"is_public"`
+	IsActive         bool      `json:"is_active" db:"is_active"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Role is a tenant-defined set of permissions granted on top of the user's
+// built-in role
+type Role struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	TenantID    uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	Permissions []string  `json:"permissions" db:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Session is a login on one device. It lives as long as its refresh token
+// family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	TenantID   string    `json:"tenant_id"`
+	ClientID   string    `json:"client_id,omitempty"`
+	DeviceName string    `json:"device_name,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// AuditEntry is a row of audit_logs. TenantID is empty for platform events
+// and ActorID for anonymous ones, such as failed logins of unknown accounts.
+type AuditEntry struct {
+	ID           uuid.UUID              `json:"id" db:"id"`
+	TenantID     *uuid.UUID             `json:"tenant_id,omitempty" db:"tenant_id"`
+	ActorID      *uuid.UUID             `json:"actor_id,omitempty" db:"user_id"`
+	Action       string                 `json:"action" db:"action"`
+	ResourceType string                 `json:"resource_type" db:"resource_type"`
+	ResourceID   *uuid.UUID             `json:"resource_id,omitempty" db:"resource_id"`
+	OldValues    map[string]interface{} `json:"old_values,omitempty" db:"old_values"`
+	NewValues    map[string]interface{} `json:"new_values,omitempty" db:"new_values"`
+	IPAddress    string                 `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent    string                 `json:"user_agent,omitempty" db:"user_agent"`
+	// ImpersonatorID is the operator behind an impersonation token, ActorID
+	// then is the impersonated user
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty" db:"impersonator_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter selects audit entries. Zero fields match everything, a zero
+// Limit returns all matching entries.
+type AuditFilter struct {
+	TenantID     *uuid.UUID
+	ActorID      *uuid.UUID
+	Action       string
+	ResourceType string
+	ResourceID   *uuid.UUID
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+// CustomerShipment is a shipment of one of the user's orders, as included
+// in personal data exports
+type CustomerShipment struct {
+	ID             uuid.UUID  `json:"id"`
+	OrderNumber    string     `json:"order_number"`
+	Provider       string     `json:"provider"`
+	TrackingNumber *string    `json:"tracking_number,omitempty"`
+	Status         string     `json:"status"`
+	PVZID          *uuid.UUID `json:"pvz_id,omitempty"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Erasure is the state of a user's erasure request
+type Erasure struct {
+	RequestedAt  *time.Time `json:"requested_at,omitempty"`
+	ScheduledAt  *time.Time `json:"scheduled_at,omitempty"`
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+}
+
+// DataExport is everything stored about a user, as handed out on a data
+// subject request
+type DataExport struct {
+	User       *User               `json:"user"`
+	Erasure    *Erasure            `json:"erasure"`
+	Sessions   []*Session          `json:"sessions"`
+	AuditLog   []*AuditEntry       `json:"audit_log"`
+	Shipments  []*CustomerShipment `json:"shipments"`
+	ExportedAt time.Time           `json:"exported_at"`
+}
+
+// APIKey is a long-lived credential of a tenant integration. Only the
+// prefix is kept in clear, the key itself is shown once on creation.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	TenantID   uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips" db:"allowed_ips"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Invitation lets a new staff member register straight into a role,
+// optionally limited to one pickup point
+type Invitation struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	TenantID       uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	Email          *string    `json:"email,omitempty" db:"email"`
+	Phone          *string    `json:"phone,omitempty" db:"phone"`
+	Role           string     `json:"role" db:"role"`
+	PVZID          *uuid.UUID `json:"pvz_id,omitempty" db:"pvz_id"`
+	Status         string     `json:"status" db:"status"`
+	InvitedBy      *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	AcceptedUserID *uuid.UUID `json:"accepted_user_id,omitempty" db:"accepted_user_id"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// UserImportJob is an asynchronous bulk import of users into a tenant
+type UserImportJob struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	TenantID        uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	CreatedBy       *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	Format          string     `json:"format" db:"format"`
+	SendInvitations bool       `json:"send_invitations" db:"send_invitations"`
+	Status          string     `json:"status" db:"status"`
+	Payload         []byte     `json:"-" db:"payload"`
+	TotalRows       int        `json:"total_rows" db:"total_rows"`
+	ProcessedRows   int        `json:"processed_rows" db:"processed_rows"`
+	CreatedRows     int        `json:"created_rows" db:"created_rows"`
+	SkippedRows     int        `json:"skipped_rows" db:"skipped_rows"`
+	FailedRows      int        `json:"failed_rows" db:"failed_rows"`
+	Error           *string    `json:"error,omitempty" db:"error"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// UserImportRow is one user of an import file. CSV files name the fields
+// in their header row, JSONL files hold one object per line.
+type UserImportRow struct {
+	Email        string  `json:"email"`
+	Phone        *string `json:"phone"`
+	FirstName    *string `json:"first_name"`
+	LastName     *string `json:"last_name"`
+	Role         string  `json:"role"`
+	PasswordHash string  `json:"password_hash"` // bcrypt, argon2 or Django PBKDF2, optional
+	IsVerified   bool    `json:"is_verified"`
+}
+
+// UserImportError reports why a row of an import was rejected. Rows are
+// numbered from 1, not counting the CSV header.
+type UserImportError struct {
+	Row     int    `json:"row" db:"row_number"`
+	Email   string `json:"email,omitempty" db:"email"`
+	Message string `json:"message" db:"message"`
+}
+
+// TenantConfig is the typed view of tenants.config
+type TenantConfig struct {
+	Auth           TenantAuthConfig     `json:"auth"`
+	PasswordPolicy TenantPasswordPolicy `json:"password_policy"`
+}
+
+type TenantAuthConfig struct {
+	RequireVerifiedEmailForLogin    bool `json:"require_verified_email_for_login"`
+	RequireVerifiedEmailForCheckout bool `json:"require_verified_email_for_checkout"`
+	// Roles that must use two-factor authentication
+	MFARequiredRoles []string `json:"mfa_required_roles"`
+}
+
+// TenantPasswordPolicy tightens the password rules for the users of a
+// tenant. The zero value keeps the platform defaults.
+type TenantPasswordPolicy struct {
+	MinLength        int  `json:"min_length"` // never below 8
+	RequireUppercase bool `json:"require_uppercase"`
+	RequireLowercase bool `json:"require_lowercase"`
+	RequireDigit     bool `json:"require_digit"`
+	RequireSymbol    bool `json:"require_symbol"`
+	// Number of previous passwords, the current one included, that cannot
+	// be reused
+	HistoryDepth int `json:"history_depth"`
+	// Days after which the password must be reset, 0 never expires
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// ParseConfig decodes the tenant config JSON, falling back to defaults
+func (t *Tenant) ParseConfig() (*TenantConfig, error) {
+	cfg := &TenantConfig{}
+	if t.Config == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(t.Config), cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // DTOs
 type RegisterRequest struct {
 	Email     string  `json:"email" binding:"required,email"`
@@ -57,6 +325,10 @@ type LoginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int64  `json:"expires_in"`
 	User         *User  `json:"user"`
+	// Set once, when a login completed a mandatory 2FA enrollment
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// Set when a passwordless login created the account
+	NewUser bool `json:"new_user,omitempty"`
 }
 
 type RefreshTokenRequest struct {
@@ -70,6 +342,13 @@ type UpdateUserRequest struct {
 	AvatarURL *string `json:"avatar_url"`
 }
 
+// AdminUpdateUserRequest is UpdateUserRequest plus the fields only admins
+// may change
+type AdminUpdateUserRequest struct {
+	UpdateUserRequest
+	Role *string `json:"role"`
+}
+
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
 	NewPassword string `json:"new_password" binding:"required,min=8"`
@@ -87,3 +366,185 @@ type ResetPasswordRequest struct {
 type VerifyEmailRequest struct {
 	Token string `json:"token" binding:"required"`
 }
+
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	MFAToken           string `json:"mfa_token"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	ExpiresIn          int64  `json:"expires_in"`
+}
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFALoginEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFALoginVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type PhoneOTPRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+type PhoneOTPVerifyRequest struct {
+	Phone string `json:"phone" binding:"required"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// CreateOAuthClientRequest registers an interactive client by default.
+// Machine clients set grant_types to ["client_credentials"] and take
+// permissions as allowed_scopes instead of redirect URIs.
+type CreateOAuthClientRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	RedirectURIs  []string `json:"redirect_uris"`
+	AllowedScopes []string `json:"allowed_scopes"`
+	GrantTypes    []string `json:"grant_types"`
+	IsPublic      bool     `json:"is_public"`
+}
+
+type CreateOAuthClientResponse struct {
+	Client       *OAuthClient `json:"client"`
+	ClientSecret string       `json:"client_secret,omitempty"` // shown once
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" binding:"required"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+type ApproveAuthorizationRequest struct {
+	RequestID string `json:"request_id" binding:"required"`
+}
+
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Scope        string `form:"scope"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// IntrospectionRequest is an RFC 7662 introspection request, made by a
+// confidential client on behalf of a resource server
+type IntrospectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectionResponse describes an active token. Inactive tokens only
+// carry Active, whatever the reason.
+type IntrospectionResponse struct {
+	Active      bool        `json:"active"`
+	Scope       string      `json:"scope,omitempty"`
+	ClientID    string      `json:"client_id,omitempty"`
+	TokenType   string      `json:"token_type,omitempty"`
+	Exp         int64       `json:"exp,omitempty"`
+	Iat         int64       `json:"iat,omitempty"`
+	Sub         string      `json:"sub,omitempty"`
+	Iss         string      `json:"iss,omitempty"`
+	Jti         string      `json:"jti,omitempty"`
+	TenantID    string      `json:"tenant_id,omitempty"`
+	Role        string      `json:"role,omitempty"`
+	Permissions []string    `json:"permissions,omitempty"`
+	Act         *TokenActor `json:"act,omitempty"` // set for impersonation tokens
+}
+
+type TokenActor struct {
+	Subject  string `json:"sub"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type AssignRoleRequest struct {
+	RoleID *uuid.UUID `json:"role_id"` // null removes the custom role
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ImpersonationResponse carries a short-lived access token acting as the
+// user. There is no refresh token, operators start over when it expires.
+type ImpersonationResponse struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int64     `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	User        *User     `json:"user"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name" binding:"required"`
+	Scopes     []string   `json:"scopes" binding:"required,min=1"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	*APIKey
+	Key string `json:"key"` // shown once
+}
+
+type CreateInvitationRequest struct {
+	Email *string    `json:"email" binding:"omitempty,email"`
+	Phone *string    `json:"phone"`
+	Role  string     `json:"role" binding:"required"`
+	PVZID *uuid.UUID `json:"pvz_id"`
+}
+
+type CreateInvitationResponse struct {
+	*Invitation
+	InviteURL string `json:"invite_url"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string  `json:"token" binding:"required"`
+	Email     string  `json:"email" binding:"omitempty,email"` // only for invitations sent by SMS
+	Password  string  `json:"password" binding:"required,min=8"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
@@ -0,0 +1,160 @@
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Limits for parameters read from stored hashes, so an imported hash
+// cannot make every login attempt against the account allocate more than a
+// few times the configured cost. Imports reject larger hashes through
+// Supported.
+const (
+	maxArgon2Memory      = 256 * 1024 // KiB
+	maxArgon2Iterations  = 16
+	maxArgon2Parallelism = 16
+)
+
+var errInvalidArgon2Hash = errors.New("invalid argon2 hash")
+
+// Argon2Params are the cost parameters of argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Argon2Hasher hashes with argon2id into the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2Hasher struct {
+	params Argon2Params
+}
+
+func NewArgon2Hasher(params Argon2Params) (*Argon2Hasher, error) {
+	if params.Memory < 8*uint32(params.Parallelism) || params.Memory > maxArgon2Memory ||
+		params.Iterations < 1 || params.Iterations > maxArgon2Iterations ||
+		params.Parallelism < 1 || params.Parallelism > maxArgon2Parallelism {
+		return nil, fmt.Errorf("invalid argon2 parameters: m=%d, t=%d, p=%d", params.Memory, params.Iterations, params.Parallelism)
+	}
+	if params.SaltLength < 16 || params.KeyLength < 16 {
+		return nil, errors.New("argon2 salt and key must be at least 16 bytes")
+	}
+	return &Argon2Hasher{params: params}, nil
+}
+
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2Hasher) NeedsRehash(hash string) bool {
+	decoded, err := parseArgon2(hash)
+	if err != nil {
+		return true
+	}
+
+	return decoded.variant != "argon2id" ||
+		decoded.params.Memory != h.params.Memory ||
+		decoded.params.Iterations != h.params.Iterations ||
+		decoded.params.Parallelism != h.params.Parallelism ||
+		decoded.params.SaltLength != h.params.SaltLength ||
+		decoded.params.KeyLength != h.params.KeyLength
+}
+
+// argon2Hash is a decoded PHC string of argon2id or argon2i
+type argon2Hash struct {
+	variant string
+	params  Argon2Params
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2(hash string) (*argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, errInvalidArgon2Hash
+	}
+
+	decoded := &argon2Hash{variant: parts[1]}
+	if decoded.variant != "argon2id" && decoded.variant != "argon2i" {
+		return nil, errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errInvalidArgon2Hash
+	}
+
+	var memory, iterations, parallelism uint32
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return nil, errInvalidArgon2Hash
+	}
+	if memory > maxArgon2Memory || iterations < 1 || iterations > maxArgon2Iterations ||
+		parallelism < 1 || parallelism > maxArgon2Parallelism {
+		return nil, errInvalidArgon2Hash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, errInvalidArgon2Hash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, errInvalidArgon2Hash
+	}
+
+	decoded.params = Argon2Params{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: uint8(parallelism),
+		SaltLength:  uint32(len(salt)),
+		KeyLength:   uint32(len(key)),
+	}
+	decoded.salt = salt
+	decoded.key = key
+
+	return decoded, nil
+}
+
+func decodeArgon2Hash(hash string) error {
+	_, err := parseArgon2(hash)
+	return err
+}
+
+func verifyArgon2(hash, password string) (bool, error) {
+	decoded, err := parseArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+
+	p := decoded.params
+	var key []byte
+	if decoded.variant == "argon2id" {
+		key = argon2.IDKey([]byte(password), decoded.salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	} else {
+		key = argon2.Key([]byte(password), decoded.salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	}
+
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+}
@@ -0,0 +1,46 @@
+package passhash
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher hashes with bcrypt at a fixed cost. Hashes of other costs
+// still verify and are upgraded on login.
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid bcrypt cost: %d", cost)
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+func decodeBcryptHash(hash string) error {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err
+}
+
+func verifyBcrypt(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
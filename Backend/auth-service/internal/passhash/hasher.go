@@ -0,0 +1,91 @@
+package passhash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"auth-service/internal/config"
+)
+
+var ErrUnsupportedHash = errors.New("unsupported password hash format")
+
+// Hasher hashes new passwords with the configured scheme. Hashes are
+// self-describing, they carry the scheme and its parameters, so hashes of
+// earlier schemes or parameters keep verifying and can be upgraded on the
+// next successful login.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether hash was made with another scheme or
+	// other parameters than Hash uses now
+	NeedsRehash(hash string) bool
+}
+
+// NewHasher picks the scheme configured by PASSWORD_HASH_ALGORITHM
+func NewHasher(cfg *config.Config) (Hasher, error) {
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id", "":
+		if cfg.Argon2Memory < 0 || cfg.Argon2Iterations < 0 || cfg.Argon2Parallelism < 0 || cfg.Argon2Parallelism > 255 {
+			return nil, fmt.Errorf("invalid argon2 parameters: m=%d, t=%d, p=%d", cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+		}
+		hasher, err := NewArgon2Hasher(Argon2Params{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+			SaltLength:  16,
+			KeyLength:   32,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return hasher, nil
+	case "bcrypt":
+		hasher, err := NewBcryptHasher(cfg.BcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		return hasher, nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm: %s", cfg.PasswordHashAlgorithm)
+	}
+}
+
+// scheme is a hash format Verify understands, recognised by its prefix
+type scheme struct {
+	prefix string
+	decode func(hash string) error // checks the hash is well-formed
+	verify func(hash, password string) (bool, error)
+}
+
+// schemes lists every format passwords may be stored in: the ones Hasher
+// produces and the ones accepted from user imports
+var schemes = []scheme{
+	{prefix: "$argon2", decode: decodeArgon2Hash, verify: verifyArgon2},
+	{prefix: "$2", decode: decodeBcryptHash, verify: verifyBcrypt},
+	{prefix: "pbkdf2_", decode: decodePBKDF2Hash, verify: verifyPBKDF2},
+}
+
+func schemeOf(hash string) (*scheme, bool) {
+	for i := range schemes {
+		if strings.HasPrefix(hash, schemes[i].prefix) {
+			return &schemes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Verify checks password against a hash of any supported scheme. A
+// mismatch is not an error.
+func Verify(hash, password string) (bool, error) {
+	s, ok := schemeOf(hash)
+	if !ok {
+		return false, ErrUnsupportedHash
+	}
+	return s.verify(hash, password)
+}
+
+// Supported reports whether hash is a well-formed hash Verify understands
+func Supported(hash string) bool {
+	s, ok := schemeOf(hash)
+	return ok && s.decode(hash) == nil
+}
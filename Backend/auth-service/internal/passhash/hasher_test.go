@@ -0,0 +1,148 @@
+package passhash
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters, the tests check formats rather than cost
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Django pbkdf2 hashes of "lètmein" with salt "seasalt" and 10000
+// iterations, as make_password would store them
+const (
+	djangoPBKDF2SHA256 = "pbkdf2_sha256$10000$seasalt$CWWFdHOWwPnki7HvkcqN9iA2T3KLW1cf2uZ5kvArtVY="
+	djangoPBKDF2SHA1   = "pbkdf2_sha1$10000$seasalt$oAfF6vgs95ncksAhGXOWf4Okq7o="
+)
+
+func testRoundTrip(t *testing.T, hasher Hasher) string {
+	t.Helper()
+
+	hash, err := hasher.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !Supported(hash) {
+		t.Errorf("Supported(%q) = false", hash)
+	}
+	if ok, err := Verify(hash, "correct horse"); err != nil || !ok {
+		t.Errorf("Verify with the right password = %v, %v", ok, err)
+	}
+	if ok, err := Verify(hash, "battery staple"); err != nil || ok {
+		t.Errorf("Verify with a wrong password = %v, %v", ok, err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Errorf("NeedsRehash of a fresh hash = true")
+	}
+
+	return hash
+}
+
+func TestArgon2RoundTrip(t *testing.T) {
+	hasher, err := NewArgon2Hasher(testArgon2Params)
+	if err != nil {
+		t.Fatalf("NewArgon2Hasher: %v", err)
+	}
+	hash := testRoundTrip(t, hasher)
+
+	stronger := testArgon2Params
+	stronger.Iterations++
+	strongerHasher, err := NewArgon2Hasher(stronger)
+	if err != nil {
+		t.Fatalf("NewArgon2Hasher: %v", err)
+	}
+	if !strongerHasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash after raising the iterations = false")
+	}
+}
+
+func TestBcryptRoundTrip(t *testing.T) {
+	hasher, err := NewBcryptHasher(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("NewBcryptHasher: %v", err)
+	}
+	hash := testRoundTrip(t, hasher)
+
+	strongerHasher, err := NewBcryptHasher(bcrypt.MinCost + 1)
+	if err != nil {
+		t.Fatalf("NewBcryptHasher: %v", err)
+	}
+	if !strongerHasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash after raising the cost = false")
+	}
+
+	argon2Hasher, err := NewArgon2Hasher(testArgon2Params)
+	if err != nil {
+		t.Fatalf("NewArgon2Hasher: %v", err)
+	}
+	if !argon2Hasher.NeedsRehash(hash) {
+		t.Error("NeedsRehash of a bcrypt hash by argon2id = false")
+	}
+}
+
+func TestPBKDF2Django(t *testing.T) {
+	argon2Hasher, err := NewArgon2Hasher(testArgon2Params)
+	if err != nil {
+		t.Fatalf("NewArgon2Hasher: %v", err)
+	}
+
+	for _, hash := range []string{djangoPBKDF2SHA256, djangoPBKDF2SHA1} {
+		if !Supported(hash) {
+			t.Errorf("Supported(%q) = false", hash)
+		}
+		if ok, err := Verify(hash, "lètmein"); err != nil || !ok {
+			t.Errorf("Verify(%q) with the right password = %v, %v", hash, ok, err)
+		}
+		if ok, err := Verify(hash, "letmein"); err != nil || ok {
+			t.Errorf("Verify(%q) with a wrong password = %v, %v", hash, ok, err)
+		}
+		if !argon2Hasher.NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%q) = false", hash)
+		}
+	}
+}
+
+func TestRejectsMalformedHashes(t *testing.T) {
+	malformed := []string{
+		"",
+		"plaintext",
+		"$1$saltsalt$md5crypt",
+		// argon2
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=524288,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=65536,t=32,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=65536,t=1,p=64$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+		"$argon2d$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5",
+		// bcrypt
+		"$2a$04$short",
+		"$2a$99$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyz01234",
+		// pbkdf2
+		"pbkdf2_sha256$10000$seasalt",
+		"pbkdf2_md5$10000$seasalt$CWWFdHOWwPnki7HvkcqN9iA2T3KLW1cf2uZ5kvArtVY=",
+		"pbkdf2_sha256$0$seasalt$CWWFdHOWwPnki7HvkcqN9iA2T3KLW1cf2uZ5kvArtVY=",
+		"pbkdf2_sha256$100000000$seasalt$CWWFdHOWwPnki7HvkcqN9iA2T3KLW1cf2uZ5kvArtVY=",
+		"pbkdf2_sha256$10000$$CWWFdHOWwPnki7HvkcqN9iA2T3KLW1cf2uZ5kvArtVY=",
+		"pbkdf2_sha256$10000$seasalt$not base64",
+	}
+
+	for _, hash := range malformed {
+		if Supported(hash) {
+			t.Errorf("Supported(%q) = true", hash)
+		}
+		if ok, err := Verify(hash, "lètmein"); err == nil || ok {
+			t.Errorf("Verify(%q) = %v, %v, want an error", hash, ok, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package passhash
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// maxPBKDF2Iterations bounds the work of verifying an imported hash
+const maxPBKDF2Iterations = 10_000_000
+
+var errInvalidPBKDF2Hash = errors.New("invalid pbkdf2 hash")
+
+// pbkdf2Digests are the PBKDF2 variants of Django's password hashers,
+// stored as <algorithm>$<iterations>$<salt>$<base64 key>. Accounts
+// migrated from such systems keep their passwords until the first login
+// upgrades them.
+var pbkdf2Digests = map[string]func() hash.Hash{
+	"pbkdf2_sha256": sha256.New,
+	"pbkdf2_sha1":   sha1.New,
+}
+
+type pbkdf2Hash struct {
+	digest     func() hash.Hash
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+func parsePBKDF2(encoded string) (*pbkdf2Hash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return nil, errInvalidPBKDF2Hash
+	}
+
+	digest, ok := pbkdf2Digests[parts[0]]
+	if !ok {
+		return nil, errInvalidPBKDF2Hash
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > maxPBKDF2Iterations {
+		return nil, errInvalidPBKDF2Hash
+	}
+
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 || parts[2] == "" {
+		return nil, errInvalidPBKDF2Hash
+	}
+
+	return &pbkdf2Hash{
+		digest:     digest,
+		iterations: iterations,
+		salt:       []byte(parts[2]),
+		key:        key,
+	}, nil
+}
+
+func decodePBKDF2Hash(encoded string) error {
+	_, err := parsePBKDF2(encoded)
+	return err
+}
+
+func verifyPBKDF2(encoded, password string) (bool, error) {
+	decoded, err := parsePBKDF2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := pbkdf2.Key([]byte(password), decoded.salt, decoded.iterations, len(decoded.key), decoded.digest)
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// apiKeyTouchInterval limits last-used updates to one per key and interval,
+// so busy integrations don't write on every request
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, tenantID, id uuid.UUID) (bool, error)
+	TouchLastUsed(ctx context.Context, id uuid.UUID, ip string) error
+}
+
+type apiKeyRepository struct {
+	db *database.TenantDB
+}
+
+func NewAPIKeyRepository(db *database.TenantDB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, tenant_id, name, prefix, key_hash, scopes, allowed_ips, created_by,
+	expires_at, last_used_at, last_used_ip, revoked_at, created_at`
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, tenant_id, name, prefix, key_hash, scopes, allowed_ips, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		key.ID,
+		key.TenantID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		pq.Array(key.Scopes),
+		pq.Array(key.AllowedIPs),
+		key.CreatedBy,
+		key.ExpiresAt,
+		key.CreatedAt,
+	)
+
+	return err
+}
+
+// GetByPrefix looks up a key by its globally unique prefix
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1`
+
+	var key *models.APIKey
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		key, err = scanAPIKey(q.QueryRowContext(ctx, query, prefix))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return key, err
+}
+
+// ListByTenant returns the keys of the tenant, revoked ones included,
+// newest first
+func (r *apiKeyRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+	`
+
+	keys := []*models.APIKey{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, tenantID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			key, err := scanAPIKey(rows)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Revoke disables a key and reports whether there was an active one
+func (r *apiKeyRepository) Revoke(ctx context.Context, tenantID, id uuid.UUID) (bool, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id, tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// TouchLastUsed records a use of the key, at most once per
+// apiKeyTouchInterval
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID, ip string) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+
+	_, err := r.db.Exec(ctx, query, id, ip, time.Now().Add(-apiKeyTouchInterval))
+	return err
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := row.Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.AllowedIPs),
+		&key.CreatedBy,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
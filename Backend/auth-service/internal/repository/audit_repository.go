@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+	Count(ctx context.Context, filter *models.AuditFilter) (int, error)
+	Each(ctx context.Context, filter *models.AuditFilter, fn func(entry *models.AuditEntry) error) error
+}
+
+type auditRepository struct {
+	db *database.TenantDB
+}
+
+func NewAuditRepository(db *database.TenantDB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_logs (id, tenant_id, user_id, impersonator_id, action, resource_type, resource_id, old_values, new_values, ip_address, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::inet, NULLIF($11, ''), $12)
+	`
+
+	oldValues, err := marshalAuditValues(entry.OldValues)
+	if err != nil {
+		return err
+	}
+	newValues, err := marshalAuditValues(entry.NewValues)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now()
+
+	_, err = r.db.Exec(ctx, query,
+		entry.ID,
+		entry.TenantID,
+		entry.ActorID,
+		entry.ImpersonatorID,
+		entry.Action,
+		entry.ResourceType,
+		entry.ResourceID,
+		oldValues,
+		newValues,
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *auditRepository) List(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	entries := []*models.AuditEntry{}
+	err := r.Each(ctx, filter, func(entry *models.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *auditRepository) Count(ctx context.Context, filter *models.AuditFilter) (int, error) {
+	where, args := auditWhere(filter)
+	query := `SELECT COUNT(*) FROM audit_logs` + where
+
+	var count int
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, args...).Scan(&count)
+	})
+
+	return count, err
+}
+
+// Each calls fn for every matching entry, newest first, without loading
+// them all into memory
+func (r *auditRepository) Each(ctx context.Context, filter *models.AuditFilter, fn func(entry *models.AuditEntry) error) error {
+	where, args := auditWhere(filter)
+	query := `
+		SELECT id, tenant_id, user_id, impersonator_id, action, resource_type, resource_id, old_values, new_values, COALESCE(host(ip_address), ''), COALESCE(user_agent, ''), created_at
+		FROM audit_logs` + where + `
+		ORDER BY created_at DESC, id`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	return r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			entry := &models.AuditEntry{}
+			var oldValues, newValues []byte
+			err := rows.Scan(
+				&entry.ID,
+				&entry.TenantID,
+				&entry.ActorID,
+				&entry.ImpersonatorID,
+				&entry.Action,
+				&entry.ResourceType,
+				&entry.ResourceID,
+				&oldValues,
+				&newValues,
+				&entry.IPAddress,
+				&entry.UserAgent,
+				&entry.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+
+			if err := unmarshalAuditValues(oldValues, &entry.OldValues); err != nil {
+				return err
+			}
+			if err := unmarshalAuditValues(newValues, &entry.NewValues); err != nil {
+				return err
+			}
+
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+func auditWhere(filter *models.AuditFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.TenantID != nil {
+		add("tenant_id = $%d", *filter.TenantID)
+	}
+	if filter.ActorID != nil {
+		add("user_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		add("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != nil {
+		add("resource_id = $%d", *filter.ResourceID)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// marshalAuditValues encodes values for a JSONB column, NULL when empty
+func marshalAuditValues(values map[string]interface{}) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func unmarshalAuditValues(data []byte, values *map[string]interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, values)
+}
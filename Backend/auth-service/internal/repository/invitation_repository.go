@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *models.Invitation) error
+	GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.Invitation, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID, status string) ([]*models.Invitation, error)
+	Revoke(ctx context.Context, tenantID, id uuid.UUID) (bool, error)
+	RevokePending(ctx context.Context, tenantID uuid.UUID, email, phone *string) error
+	Accept(ctx context.Context, invitation *models.Invitation, user *models.User) (bool, error)
+	PVZExists(ctx context.Context, tenantID, pvzID uuid.UUID) (bool, error)
+}
+
+type invitationRepository struct {
+	db *database.TenantDB
+}
+
+func NewInvitationRepository(db *database.TenantDB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+const invitationColumns = `id, tenant_id, email, phone, role, pvz_id, status, invited_by, accepted_user_id,
+	expires_at, accepted_at, revoked_at, created_at, updated_at`
+
+func (r *invitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	query := `
+		INSERT INTO invitations (id, tenant_id, email, phone, role, pvz_id, status, invited_by, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	invitation.ID = uuid.New()
+	invitation.CreatedAt = time.Now()
+	invitation.UpdatedAt = invitation.CreatedAt
+
+	_, err := r.db.Exec(ctx, query,
+		invitation.ID,
+		invitation.TenantID,
+		invitation.Email,
+		invitation.Phone,
+		invitation.Role,
+		invitation.PVZID,
+		invitation.Status,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+		invitation.CreatedAt,
+		invitation.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *invitationRepository) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM invitations WHERE id = $1 AND tenant_id = $2`
+
+	var invitation *models.Invitation
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		invitation, err = scanInvitation(q.QueryRowContext(ctx, query, id, tenantID))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return invitation, err
+}
+
+// ListByTenant returns the invitations of the tenant, newest first. An
+// empty status matches all of them.
+func (r *invitationRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, status string) ([]*models.Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM invitations
+		WHERE tenant_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+	`
+
+	invitations := []*models.Invitation{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, tenantID, status)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			invitation, err := scanInvitation(rows)
+			if err != nil {
+				return err
+			}
+			invitations = append(invitations, invitation)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// Revoke withdraws a pending invitation and reports whether there was one
+func (r *invitationRepository) Revoke(ctx context.Context, tenantID, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE invitations
+		SET status = 'revoked', revoked_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND tenant_id = $2 AND status = 'pending'
+	`
+
+	result, err := r.db.Exec(ctx, query, id, tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RevokePending withdraws the pending invitations sent to the email or
+// phone, so that only the latest one can be accepted
+func (r *invitationRepository) RevokePending(ctx context.Context, tenantID uuid.UUID, email, phone *string) error {
+	query := `
+		UPDATE invitations
+		SET status = 'revoked', revoked_at = NOW(), updated_at = NOW()
+		WHERE tenant_id = $1 AND status = 'pending' AND (email = $2 OR phone = $3)
+	`
+
+	_, err := r.db.Exec(ctx, query, tenantID, email, phone)
+	return err
+}
+
+// Accept marks the invitation accepted and creates its user in one
+// transaction. It reports false when the invitation is no longer pending or
+// has expired.
+func (r *invitationRepository) Accept(ctx context.Context, invitation *models.Invitation, user *models.User) (bool, error) {
+	var accepted bool
+
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		result, err := q.ExecContext(ctx, `
+			UPDATE invitations
+			SET status = 'accepted', accepted_user_id = $1, accepted_at = NOW(), updated_at = NOW()
+			WHERE id = $2 AND status = 'pending' AND expires_at > NOW()
+		`, user.ID, invitation.ID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+		accepted = true
+
+		_, err = q.ExecContext(ctx, `
+			INSERT INTO users (id, tenant_id, email, password_hash, first_name, last_name, phone, role, pvz_id, is_verified, is_active, created_at, updated_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		`,
+			user.ID,
+			user.TenantID,
+			user.Email,
+			user.PasswordHash,
+			user.FirstName,
+			user.LastName,
+			user.Phone,
+			user.Role,
+			user.PVZID,
+			user.IsVerified,
+			user.IsActive,
+			user.CreatedAt,
+			user.UpdatedAt,
+		)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return accepted, nil
+}
+
+// PVZExists reports whether the pickup point belongs to the tenant
+func (r *invitationRepository) PVZExists(ctx context.Context, tenantID, pvzID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM pvz_locations WHERE id = $1 AND tenant_id = $2)`
+
+	var exists bool
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, pvzID, tenantID).Scan(&exists)
+	})
+
+	return exists, err
+}
+
+func scanInvitation(row rowScanner) (*models.Invitation, error) {
+	invitation := &models.Invitation{}
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.TenantID,
+		&invitation.Email,
+		&invitation.Phone,
+		&invitation.Role,
+		&invitation.PVZID,
+		&invitation.Status,
+		&invitation.InvitedBy,
+		&invitation.AcceptedUserID,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.RevokedAt,
+		&invitation.CreatedAt,
+		&invitation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
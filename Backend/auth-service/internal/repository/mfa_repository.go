@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type MFARepository interface {
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*models.UserMFA, error)
+	Upsert(ctx context.Context, mfa *models.UserMFA) error
+	Enable(ctx context.Context, userID uuid.UUID, recoveryCodes []string) error
+	UpdateRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodes []string) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+type mfaRepository struct {
+	db *database.TenantDB
+}
+
+func NewMFARepository(db *database.TenantDB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*models.UserMFA, error) {
+	query := `
+		SELECT user_id, tenant_id, totp_secret, is_enabled, recovery_codes, enabled_at, created_at, updated_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	mfa := &models.UserMFA{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, userID).Scan(
+			&mfa.UserID,
+			&mfa.TenantID,
+			&mfa.TOTPSecret,
+			&mfa.IsEnabled,
+			pq.Array(&mfa.RecoveryCodes),
+			&mfa.EnabledAt,
+			&mfa.CreatedAt,
+			&mfa.UpdatedAt,
+		)
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return mfa, err
+}
+
+// Upsert stores a pending (not yet enabled) TOTP secret, replacing any
+// previous enrollment
+func (r *mfaRepository) Upsert(ctx context.Context, mfa *models.UserMFA) error {
+	query := `
+		INSERT INTO user_mfa (user_id, tenant_id, totp_secret, is_enabled, recovery_codes, created_at, updated_at)
+		VALUES ($1, $2, $3, FALSE, '{}', $4, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, is_enabled = FALSE, recovery_codes = '{}',
+			enabled_at = NULL, updated_at = EXCLUDED.updated_at
+	`
+
+	mfa.CreatedAt = time.Now()
+	mfa.UpdatedAt = mfa.CreatedAt
+	mfa.IsEnabled = false
+	mfa.RecoveryCodes = nil
+
+	_, err := r.db.Exec(ctx, query, mfa.UserID, mfa.TenantID, mfa.TOTPSecret, mfa.CreatedAt)
+	return err
+}
+
+func (r *mfaRepository) Enable(ctx context.Context, userID uuid.UUID, recoveryCodes []string) error {
+	query := `
+		UPDATE user_mfa
+		SET is_enabled = TRUE, recovery_codes = $1, enabled_at = $2, updated_at = $2
+		WHERE user_id = $3
+	`
+
+	_, err := r.db.Exec(ctx, query, pq.Array(recoveryCodes), time.Now(), userID)
+	return err
+}
+
+func (r *mfaRepository) UpdateRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodes []string) error {
+	query := `UPDATE user_mfa SET recovery_codes = $1, updated_at = $2 WHERE user_id = $3`
+	_, err := r.db.Exec(ctx, query, pq.Array(recoveryCodes), time.Now(), userID)
+	return err
+}
+
+// UseRecoveryCode removes the code from the enabled 2FA of the user. Only
+// the first of concurrent requests spending the same code removes it.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET recovery_codes = array_remove(recovery_codes, $1), updated_at = $2
+		WHERE user_id = $3 AND is_enabled AND $1 = ANY(recovery_codes)
+	`
+
+	result, err := r.db.Exec(ctx, query, codeHash, time.Now(), userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *mfaRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM user_mfa WHERE user_id = $1`
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
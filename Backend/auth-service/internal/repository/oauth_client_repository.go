@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type OAuthClientRepository interface {
+	Create(ctx context.Context, client *models.OAuthClient) error
+	GetByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.OAuthClient, error)
+	Delete(ctx context.Context, tenantID uuid.UUID, id uuid.UUID) (bool, error)
+}
+
+type oauthClientRepository struct {
+	db *database.TenantDB
+}
+
+func NewOAuthClientRepository(db *database.TenantDB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+const oauthClientColumns = `id, tenant_id, client_id, client_secret_hash, name, redirect_uris, allowed_scopes, grant_types,
+	is_public, is_active, created_at, updated_at`
+
+func (r *oauthClientRepository) Create(ctx context.Context, client *models.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (id, tenant_id, client_id, client_secret_hash, name, redirect_uris, allowed_scopes, grant_types, is_public, is_active, created_at, updated_at)
+		VALUES ($1, NULLIF($2, '00000000-0000-0000-0000-000000000000'::uuid), $3, $4, $5, $6, $7, $8, $9, TRUE, $10, $11)
+	`
+
+	client.ID = uuid.New()
+	client.IsActive = true
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		client.ID,
+		client.TenantID,
+		client.ClientID,
+		client.ClientSecretHash,
+		client.Name,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.AllowedScopes),
+		pq.Array(client.GrantTypes),
+		client.IsPublic,
+		client.CreatedAt,
+		client.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *oauthClientRepository) GetByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	query := `
+		SELECT ` + oauthClientColumns + `
+		FROM oauth_clients
+		WHERE client_id = $1
+	`
+
+	var client *models.OAuthClient
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		client, err = scanOAuthClient(q.QueryRowContext(ctx, query, clientID))
+		return err
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return client, err
+}
+
+// ListByTenant returns the clients of the tenant, or the platform-wide
+// clients when tenantID is uuid.Nil
+func (r *oauthClientRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.OAuthClient, error) {
+	query := `
+		SELECT ` + oauthClientColumns + `
+		FROM oauth_clients
+		WHERE tenant_id IS NOT DISTINCT FROM NULLIF($1, '00000000-0000-0000-0000-000000000000'::uuid)
+		ORDER BY created_at DESC
+	`
+
+	clients := []*models.OAuthClient{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, tenantID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			client, err := scanOAuthClient(rows)
+			if err != nil {
+				return err
+			}
+			clients = append(clients, client)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (r *oauthClientRepository) Delete(ctx context.Context, tenantID uuid.UUID, id uuid.UUID) (bool, error) {
+	query := `
+		DELETE FROM oauth_clients
+		WHERE id = $1 AND tenant_id IS NOT DISTINCT FROM NULLIF($2, '00000000-0000-0000-0000-000000000000'::uuid)
+	`
+	result, err := r.db.Exec(ctx, query, id, tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanOAuthClient(row rowScanner) (*models.OAuthClient, error) {
+	client := &models.OAuthClient{}
+	err := row.Scan(
+		&client.ID,
+		&client.TenantID,
+		&client.ClientID,
+		&client.ClientSecretHash,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.AllowedScopes),
+		pq.Array(&client.GrantTypes),
+		&client.IsPublic,
+		&client.IsActive,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
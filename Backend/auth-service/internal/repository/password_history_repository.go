@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type PasswordHistoryRepository interface {
+	Add(ctx context.Context, userID, tenantID uuid.UUID, passwordHash string, keep int) error
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+	LastChangedAt(ctx context.Context, userID uuid.UUID) (*time.Time, error)
+}
+
+// Password history keeps the hashes of a user's last passwords, newest
+// first. The newest entry is the current password.
+type passwordHistoryRepository struct {
+	db *database.TenantDB
+}
+
+func NewPasswordHistoryRepository(db *database.TenantDB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+// Add records a new password of the user and drops all but the keep most
+// recent entries
+func (r *passwordHistoryRepository) Add(ctx context.Context, userID, tenantID uuid.UUID, passwordHash string, keep int) error {
+	return r.db.Run(ctx, func(q database.Querier) error {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO password_history (id, user_id, tenant_id, password_hash, created_at)
+			VALUES ($1, $2, NULLIF($3, '00000000-0000-0000-0000-000000000000'::uuid), $4, $5)
+		`, uuid.New(), userID, tenantID, passwordHash, time.Now())
+		if err != nil {
+			return err
+		}
+
+		_, err = q.ExecContext(ctx, `
+			DELETE FROM password_history
+			WHERE user_id = $1 AND id NOT IN (
+				SELECT id FROM password_history
+				WHERE user_id = $1
+				ORDER BY created_at DESC
+				LIMIT $2
+			)
+		`, userID, keep)
+		return err
+	})
+}
+
+// ListRecent returns the hashes of the user's last passwords, newest first
+func (r *passwordHistoryRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	query := `
+		SELECT password_hash
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	hashes := []string{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, userID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var hash string
+			if err := rows.Scan(&hash); err != nil {
+				return err
+			}
+			hashes = append(hashes, hash)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// LastChangedAt returns when the user last set a password, nil if no
+// change was recorded
+func (r *passwordHistoryRepository) LastChangedAt(ctx context.Context, userID uuid.UUID) (*time.Time, error) {
+	query := `SELECT MAX(created_at) FROM password_history WHERE user_id = $1`
+
+	var changedAt sql.NullTime
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, userID).Scan(&changedAt)
+	})
+	if err != nil || !changedAt.Valid {
+		return nil, err
+	}
+
+	return &changedAt.Time, nil
+}
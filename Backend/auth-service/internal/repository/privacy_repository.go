@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// auditPIIKeys are removed from the audit trail of an anonymized user
+var auditPIIKeys = []string{"email", "phone", "first_name", "last_name"}
+
+// PrivacyRepository serves data subject requests: it collects the records
+// about a user and erases their personal data
+type PrivacyRepository interface {
+	ListShipments(ctx context.Context, userID uuid.UUID) ([]*models.CustomerShipment, error)
+	GetErasure(ctx context.Context, userID uuid.UUID) (*models.Erasure, error)
+	ScheduleErasure(ctx context.Context, userID uuid.UUID, at time.Time) (bool, error)
+	CancelErasure(ctx context.Context, userID uuid.UUID) (bool, error)
+	ListDueErasures(ctx context.Context, now time.Time, limit int) ([]*models.User, error)
+	Anonymize(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+type privacyRepository struct {
+	db *database.TenantDB
+}
+
+func NewPrivacyRepository(db *database.TenantDB) PrivacyRepository {
+	return &privacyRepository{db: db}
+}
+
+// ListShipments returns the shipments of the orders the user placed
+func (r *privacyRepository) ListShipments(ctx context.Context, userID uuid.UUID) ([]*models.CustomerShipment, error) {
+	query := `
+		SELECT s.id, o.order_number, s.provider, s.tracking_number, s.status, s.pvz_id, s.shipped_at, s.delivered_at, s.created_at
+		FROM shipments s
+		JOIN orders o ON o.id = s.order_id
+		WHERE o.customer_id = $1
+		ORDER BY s.created_at DESC
+	`
+
+	shipments := []*models.CustomerShipment{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			shipment := &models.CustomerShipment{}
+			err := rows.Scan(
+				&shipment.ID,
+				&shipment.OrderNumber,
+				&shipment.Provider,
+				&shipment.TrackingNumber,
+				&shipment.Status,
+				&shipment.PVZID,
+				&shipment.ShippedAt,
+				&shipment.DeliveredAt,
+				&shipment.CreatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			shipments = append(shipments, shipment)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipments, nil
+}
+
+func (r *privacyRepository) GetErasure(ctx context.Context, userID uuid.UUID) (*models.Erasure, error) {
+	query := `SELECT erasure_requested_at, erasure_scheduled_at, anonymized_at FROM users WHERE id = $1`
+
+	erasure := &models.Erasure{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, userID).Scan(&erasure.RequestedAt, &erasure.ScheduledAt, &erasure.AnonymizedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return erasure, err
+}
+
+// ScheduleErasure records an erasure request. It reports false for users
+// that are already anonymized or have a pending request.
+func (r *privacyRepository) ScheduleErasure(ctx context.Context, userID uuid.UUID, at time.Time) (bool, error) {
+	query := `
+		UPDATE users
+		SET erasure_requested_at = NOW(), erasure_scheduled_at = $1, updated_at = NOW()
+		WHERE id = $2 AND anonymized_at IS NULL AND erasure_scheduled_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, at, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CancelErasure withdraws a pending erasure request
+func (r *privacyRepository) CancelErasure(ctx context.Context, userID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users
+		SET erasure_requested_at = NULL, erasure_scheduled_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND anonymized_at IS NULL AND erasure_scheduled_at IS NOT NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ListDueErasures returns users whose grace period has ended, oldest first
+func (r *privacyRepository) ListDueErasures(ctx context.Context, now time.Time, limit int) ([]*models.User, error) {
+	query := `
+		SELECT id, tenant_id
+		FROM users
+		WHERE erasure_scheduled_at <= $1 AND anonymized_at IS NULL
+		ORDER BY erasure_scheduled_at
+		LIMIT $2
+	`
+
+	users := []*models.User{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, now, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user := &models.User{}
+			if err := rows.Scan(&user.ID, &user.TenantID); err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Anonymize removes the user's personal data in one transaction. The user
+// row, orders, notifications and audit entries stay for accounting and
+// compliance; their contact details, delivery addresses and message texts
+// are cleared and the account can no longer sign in.
+func (r *privacyRepository) Anonymize(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var anonymized bool
+
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		result, err := q.ExecContext(ctx, `
+			UPDATE users
+			SET email = NULL, phone = NULL, first_name = NULL, last_name = NULL, avatar_url = NULL,
+				password_hash = '', metadata = '{}', custom_role_id = NULL, is_active = FALSE,
+				erasure_scheduled_at = NULL, anonymized_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND anonymized_at IS NULL
+		`, userID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+		anonymized = true
+
+		if _, err := q.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		if _, err := q.ExecContext(ctx, `
+			UPDATE orders
+			SET customer_email = NULL, customer_phone = NULL, customer_note = NULL, delivery_address = NULL,
+				updated_at = NOW()
+			WHERE customer_id = $1
+		`, userID); err != nil {
+			return err
+		}
+
+		// recipient and body are NOT NULL, the delivery status stays
+		if _, err := q.ExecContext(ctx, `
+			UPDATE notifications
+			SET recipient = 'redacted', subject = NULL, body = '', metadata = '{}'
+			WHERE user_id = $1
+		`, userID); err != nil {
+			return err
+		}
+
+		_, err = q.ExecContext(ctx, `
+			UPDATE audit_logs
+			SET old_values = old_values - $2::text[], new_values = new_values - $2::text[]
+			WHERE resource_id = $1
+		`, userID, pq.Array(auditPIIKeys))
+		return err
+	})
+
+	return anonymized, err
+}
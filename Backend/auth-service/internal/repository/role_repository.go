@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type RoleRepository interface {
+	Create(ctx context.Context, role *models.Role) error
+	GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.Role, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.Role, error)
+	Update(ctx context.Context, role *models.Role) (bool, error)
+	Delete(ctx context.Context, tenantID, id uuid.UUID) (bool, error)
+	GetForUser(ctx context.Context, userID uuid.UUID) (*models.Role, error)
+	AssignToUser(ctx context.Context, tenantID, userID uuid.UUID, roleID *uuid.UUID) (bool, error)
+	ListHolders(ctx context.Context, tenantID, id uuid.UUID) ([]uuid.UUID, error)
+}
+
+type roleRepository struct {
+	db *database.TenantDB
+}
+
+func NewRoleRepository(db *database.TenantDB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *models.Role) error {
+	query := `
+		INSERT INTO roles (id, tenant_id, name, description, permissions, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	role.ID = uuid.New()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		role.ID,
+		role.TenantID,
+		role.Name,
+		role.Description,
+		pq.Array(role.Permissions),
+		role.CreatedAt,
+		role.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *roleRepository) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.Role, error) {
+	query := `
+		SELECT id, tenant_id, name, description, permissions, created_at, updated_at
+		FROM roles
+		WHERE id = $1 AND tenant_id = $2
+	`
+
+	return r.queryOne(ctx, query, id, tenantID)
+}
+
+func (r *roleRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*models.Role, error) {
+	query := `
+		SELECT id, tenant_id, name, description, permissions, created_at, updated_at
+		FROM roles
+		WHERE tenant_id = $1
+		ORDER BY name
+	`
+
+	roles := []*models.Role{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, tenantID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			role := &models.Role{}
+			err := rows.Scan(
+				&role.ID,
+				&role.TenantID,
+				&role.Name,
+				&role.Description,
+				pq.Array(&role.Permissions),
+				&role.CreatedAt,
+				&role.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			roles = append(roles, role)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) Update(ctx context.Context, role *models.Role) (bool, error) {
+	query := `
+		UPDATE roles
+		SET name = $1, description = $2, permissions = $3, updated_at = $4
+		WHERE id = $5 AND tenant_id = $6
+	`
+
+	role.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(ctx, query,
+		role.Name,
+		role.Description,
+		pq.Array(role.Permissions),
+		role.UpdatedAt,
+		role.ID,
+		role.TenantID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *roleRepository) Delete(ctx context.Context, tenantID, id uuid.UUID) (bool, error) {
+	query := `DELETE FROM roles WHERE id = $1 AND tenant_id = $2`
+	result, err := r.db.Exec(ctx, query, id, tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetForUser returns the custom role assigned to the user, if any
+func (r *roleRepository) GetForUser(ctx context.Context, userID uuid.UUID) (*models.Role, error) {
+	query := `
+		SELECT r.id, r.tenant_id, r.name, r.description, r.permissions, r.created_at, r.updated_at
+		FROM roles r
+		JOIN users u ON u.custom_role_id = r.id
+		WHERE u.id = $1
+	`
+
+	return r.queryOne(ctx, query, userID)
+}
+
+// AssignToUser sets or clears the user's custom role. The role must belong
+// to the user's tenant.
+func (r *roleRepository) AssignToUser(ctx context.Context, tenantID, userID uuid.UUID, roleID *uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users
+		SET custom_role_id = $1, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`
+
+	result, err := r.db.Exec(ctx, query, roleID, time.Now(), userID, tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ListHolders returns the IDs of the users the role is assigned to
+func (r *roleRepository) ListHolders(ctx context.Context, tenantID, id uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT id FROM users WHERE tenant_id = $1 AND custom_role_id = $2`
+
+	userIDs := []uuid.UUID{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, tenantID, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userID uuid.UUID
+			if err := rows.Scan(&userID); err != nil {
+				return err
+			}
+			userIDs = append(userIDs, userID)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+func (r *roleRepository) queryOne(ctx context.Context, query string, args ...interface{}) (*models.Role, error) {
+	role := &models.Role{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, args...).Scan(
+			&role.ID,
+			&role.TenantID,
+			&role.Name,
+			&role.Description,
+			pq.Array(&role.Permissions),
+			&role.CreatedAt,
+			&role.UpdatedAt,
+		)
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return role, err
+}
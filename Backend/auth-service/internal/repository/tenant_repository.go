@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type TenantRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Tenant, error)
+	GetBySubdomain(ctx context.Context, subdomain string) (*models.Tenant, error)
+	GetByCustomDomain(ctx context.Context, domain string) (*models.Tenant, error)
+}
+
+type tenantRepository struct {
+	db *sql.DB
+}
+
+func NewTenantRepository(db *sql.DB) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+const tenantColumns = `id, subdomain, custom_domain, name, tier, status, country, config, billing_info, created_at, updated_at`
+
+func (r *tenantRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Tenant, error) {
+	query := `
+		SELECT ` + tenantColumns + `
+		FROM tenants
+		WHERE id = $1
+	`
+
+	return r.queryTenant(ctx, query, id)
+}
+
+func (r *tenantRepository) GetBySubdomain(ctx context.Context, subdomain string) (*models.Tenant, error) {
+	query := `
+		SELECT ` + tenantColumns + `
+		FROM tenants
+		WHERE subdomain = $1
+	`
+
+	return r.queryTenant(ctx, query, subdomain)
+}
+
+func (r *tenantRepository) GetByCustomDomain(ctx context.Context, domain string) (*models.Tenant, error) {
+	query := `
+		SELECT ` + tenantColumns + `
+		FROM tenants
+		WHERE custom_domain = $1
+	`
+
+	return r.queryTenant(ctx, query, domain)
+}
+
+func (r *tenantRepository) queryTenant(ctx context.Context, query string, args ...interface{}) (*models.Tenant, error) {
+	tenant := &models.Tenant{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&tenant.ID,
+		&tenant.Subdomain,
+		&tenant.CustomDomain,
+		&tenant.Name,
+		&tenant.Tier,
+		&tenant.Status,
+		&tenant.Country,
+		&tenant.Config,
+		&tenant.BillingInfo,
+		&tenant.CreatedAt,
+		&tenant.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return tenant, err
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type UserImportRepository interface {
+	Create(ctx context.Context, job *models.UserImportJob) error
+	GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.UserImportJob, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID, limit int) ([]*models.UserImportJob, error)
+	ClaimNext(ctx context.Context, staleBefore time.Time) (*models.UserImportJob, error)
+	UpdateProgress(ctx context.Context, job *models.UserImportJob) error
+	Finish(ctx context.Context, job *models.UserImportJob) error
+	AddErrors(ctx context.Context, job *models.UserImportJob, errs []*models.UserImportError) error
+	ListErrors(ctx context.Context, jobID uuid.UUID, limit, offset int) ([]*models.UserImportError, error)
+}
+
+type userImportRepository struct {
+	db *database.TenantDB
+}
+
+func NewUserImportRepository(db *database.TenantDB) UserImportRepository {
+	return &userImportRepository{db: db}
+}
+
+const userImportJobColumns = `id, tenant_id, created_by, format, send_invitations, status, total_rows, processed_rows,
+	created_rows, skipped_rows, failed_rows, error, created_at, updated_at, started_at, finished_at`
+
+func (r *userImportRepository) Create(ctx context.Context, job *models.UserImportJob) error {
+	query := `
+		INSERT INTO user_import_jobs (id, tenant_id, created_by, format, send_invitations, status, payload, total_rows, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	job.ID = uuid.New()
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+
+	_, err := r.db.Exec(ctx, query,
+		job.ID,
+		job.TenantID,
+		job.CreatedBy,
+		job.Format,
+		job.SendInvitations,
+		job.Status,
+		job.Payload,
+		job.TotalRows,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *userImportRepository) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.UserImportJob, error) {
+	query := `SELECT ` + userImportJobColumns + ` FROM user_import_jobs WHERE id = $1 AND tenant_id = $2`
+
+	var job *models.UserImportJob
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		job, err = scanUserImportJob(q.QueryRowContext(ctx, query, id, tenantID), false)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return job, err
+}
+
+// ListByTenant returns the latest jobs of the tenant, newest first
+func (r *userImportRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, limit int) ([]*models.UserImportJob, error) {
+	query := `
+		SELECT ` + userImportJobColumns + `
+		FROM user_import_jobs
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	jobs := []*models.UserImportJob{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, tenantID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			job, err := scanUserImportJob(rows, false)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// ClaimNext marks the oldest pending job as running and returns it with its
+// payload, or nil when there is none. Running jobs that made no progress
+// since staleBefore are claimed again, from the start. Concurrent workers
+// never claim the same job.
+func (r *userImportRepository) ClaimNext(ctx context.Context, staleBefore time.Time) (*models.UserImportJob, error) {
+	query := `
+		UPDATE user_import_jobs
+		SET status = 'running', processed_rows = 0, created_rows = 0, skipped_rows = 0, failed_rows = 0,
+			started_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_import_jobs
+			WHERE status = 'pending' OR (status = 'running' AND updated_at < $1)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + userImportJobColumns + `, payload
+	`
+
+	var job *models.UserImportJob
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		job, err = scanUserImportJob(q.QueryRowContext(ctx, query, staleBefore), true)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return job, err
+}
+
+// UpdateProgress stores the counters of a running job
+func (r *userImportRepository) UpdateProgress(ctx context.Context, job *models.UserImportJob) error {
+	query := `
+		UPDATE user_import_jobs
+		SET processed_rows = $1, created_rows = $2, skipped_rows = $3, failed_rows = $4, updated_at = NOW()
+		WHERE id = $5
+	`
+
+	_, err := r.db.Exec(ctx, query, job.ProcessedRows, job.CreatedRows, job.SkippedRows, job.FailedRows, job.ID)
+	return err
+}
+
+// Finish stores the final status and counters of a job and drops the
+// uploaded file
+func (r *userImportRepository) Finish(ctx context.Context, job *models.UserImportJob) error {
+	query := `
+		UPDATE user_import_jobs
+		SET status = $1, error = $2, processed_rows = $3, created_rows = $4, skipped_rows = $5, failed_rows = $6,
+			payload = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $7
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		job.Status,
+		job.Error,
+		job.ProcessedRows,
+		job.CreatedRows,
+		job.SkippedRows,
+		job.FailedRows,
+		job.ID,
+	)
+
+	return err
+}
+
+// AddErrors stores rejected rows. A job that is run again replaces the
+// errors of its earlier attempt.
+func (r *userImportRepository) AddErrors(ctx context.Context, job *models.UserImportJob, errs []*models.UserImportError) error {
+	query := `
+		INSERT INTO user_import_errors (job_id, tenant_id, row_number, email, message)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (job_id, row_number) DO UPDATE SET email = EXCLUDED.email, message = EXCLUDED.message
+	`
+
+	return r.db.Run(ctx, func(q database.Querier) error {
+		for _, e := range errs {
+			if _, err := q.ExecContext(ctx, query, job.ID, job.TenantID, e.Row, e.Email, e.Message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *userImportRepository) ListErrors(ctx context.Context, jobID uuid.UUID, limit, offset int) ([]*models.UserImportError, error) {
+	query := `
+		SELECT row_number, COALESCE(email, ''), message
+		FROM user_import_errors
+		WHERE job_id = $1
+		ORDER BY row_number
+		LIMIT $2 OFFSET $3
+	`
+
+	errs := []*models.UserImportError{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, jobID, limit, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			e := &models.UserImportError{}
+			if err := rows.Scan(&e.Row, &e.Email, &e.Message); err != nil {
+				return err
+			}
+			errs = append(errs, e)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUserImportJob reads the job columns, followed by the payload when
+// withPayload is set
+func scanUserImportJob(row rowScanner, withPayload bool) (*models.UserImportJob, error) {
+	job := &models.UserImportJob{}
+	dest := []interface{}{
+		&job.ID,
+		&job.TenantID,
+		&job.CreatedBy,
+		&job.Format,
+		&job.SendInvitations,
+		&job.Status,
+		&job.TotalRows,
+		&job.ProcessedRows,
+		&job.CreatedRows,
+		&job.SkippedRows,
+		&job.FailedRows,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	}
+	if withPayload {
+		dest = append(dest, &job.Payload)
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
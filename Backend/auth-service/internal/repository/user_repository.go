@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
+	"auth-service/internal/database"
 	"auth-service/internal/models"
 
 	"github.com/google/uuid"
@@ -12,32 +15,40 @@ import (
 
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
+	CreateIfNotExists(ctx context.Context, user *models.User) (bool, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
-	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByEmail(ctx context.Context, tenantID uuid.UUID, email string) (*models.User, error)
+	FindByPhone(ctx context.Context, tenantID uuid.UUID, phone string) (*models.User, error)
 	Update(ctx context.Context, user *models.User) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	ListByTenant(ctx context.Context, tenantID uuid.UUID, limit, offset int) ([]*models.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkVerified(ctx context.Context, id uuid.UUID) error
+	UpdateLastLogin(ctx context.Context, id uuid.UUID) error
+	Search(ctx context.Context, filter *models.UserFilter) ([]*models.User, error)
+	Each(ctx context.Context, filter *models.UserFilter, fn func(user *models.User) error) error
 }
 
+// Users are scoped by tenant: emails and phones are only unique within a
+// tenant. uuid.Nil stands for the platform scope of super admins, stored
+// with a NULL tenant_id.
 type userRepository struct {
-	db *sql.DB
+	db *database.TenantDB
 }
 
-func NewUserRepository(db *sql.DB) UserRepository {
+func NewUserRepository(db *database.TenantDB) UserRepository {
 	return &userRepository{db: db}
 }
 
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
 		INSERT INTO users (id, tenant_id, email, password_hash, first_name, last_name, phone, role, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		VALUES ($1, NULLIF($2, '00000000-0000-0000-0000-000000000000'::uuid), NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)
 	`
 
 	user.ID = uuid.New()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := r.db.Exec(ctx, query,
 		user.ID,
 		user.TenantID,
 		user.Email,
@@ -53,62 +64,82 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	return err
 }
 
-func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+// CreateIfNotExists creates the user unless the email or phone is already
+// registered in the tenant, and reports whether it did
+func (r *userRepository) CreateIfNotExists(ctx context.Context, user *models.User) (bool, error) {
 	query := `
-		SELECT id, tenant_id, email, password_hash, first_name, last_name, phone, role, is_active, created_at, updated_at
-		FROM users
-		WHERE id = $1
+		INSERT INTO users (id, tenant_id, email, password_hash, first_name, last_name, phone, role, is_verified, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT DO NOTHING
 	`
 
-	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.TenantID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.FirstName,
-		&user.LastName,
-		&user.Phone,
-		&user.Role,
-		&user.IsActive,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+	result, err := r.db.Exec(ctx, query,
+		user.ID,
+		user.TenantID,
+		user.Email,
+		user.PasswordHash,
+		user.FirstName,
+		user.LastName,
+		user.Phone,
+		user.Role,
+		user.IsVerified,
+		user.IsActive,
+		user.CreatedAt,
+		user.UpdatedAt,
+	)
+	if err != nil {
+		return false, err
 	}
 
-	return user, err
+	affected, err := result.RowsAffected()
+	return affected > 0, err
 }
 
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, tenant_id, email, password_hash, first_name, last_name, phone, role, is_active, created_at, updated_at
+		SELECT id, tenant_id, COALESCE(email, ''), password_hash, first_name, last_name, phone, role, pvz_id, is_verified, is_active, created_at, updated_at
 		FROM users
-		WHERE email = $1
+		WHERE id = $1
 	`
 
-	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.TenantID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.FirstName,
-		&user.LastName,
-		&user.Phone,
-		&user.Role,
-		&user.IsActive,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	return r.queryOne(ctx, query, id)
+}
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+// GetByEmail looks up a user by email within a tenant, or among the
+// platform identities when tenantID is uuid.Nil
+func (r *userRepository) GetByEmail(ctx context.Context, tenantID uuid.UUID, email string) (*models.User, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(email, ''), password_hash, first_name, last_name, phone, role, pvz_id, is_verified, is_active, created_at, updated_at
+		FROM users
+		WHERE tenant_id = $1 AND email = $2
+	`
+	args := []interface{}{tenantID, email}
+
+	if tenantID == uuid.Nil {
+		query = `
+			SELECT id, tenant_id, COALESCE(email, ''), password_hash, first_name, last_name, phone, role, pvz_id, is_verified, is_active, created_at, updated_at
+			FROM users
+			WHERE tenant_id IS NULL AND email = $1
+		`
+		args = []interface{}{email}
 	}
 
-	return user, err
+	return r.queryOne(ctx, query, args...)
+}
+
+// FindByPhone looks up a user by normalized phone number within a tenant
+func (r *userRepository) FindByPhone(ctx context.Context, tenantID uuid.UUID, phone string) (*models.User, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(email, ''), password_hash, first_name, last_name, phone, role, pvz_id, is_verified, is_active, created_at, updated_at
+		FROM users
+		WHERE tenant_id = $1 AND phone = $2
+	`
+
+	return r.queryOne(ctx, query, tenantID, phone)
 }
 
 func (r *userRepository) Update(ctx context.Context, user *models.User) error {
@@ -120,7 +151,7 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 
 	user.UpdatedAt = time.Now()
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := r.db.Exec(ctx, query,
 		user.FirstName,
 		user.LastName,
 		user.Phone,
@@ -133,31 +164,98 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	return err
 }
 
-func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.Exec(ctx, query, passwordHash, time.Now(), id)
 	return err
 }
 
-func (r *userRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, limit, offset int) ([]*models.User, error) {
-	query := `
-		SELECT id, tenant_id, email, password_hash, first_name, last_name, phone, role, is_active, created_at, updated_at
-		FROM users
-		WHERE tenant_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+func (r *userRepository) MarkVerified(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET is_verified = TRUE, updated_at = $1 WHERE id = $2`
+	_, err := r.db.Exec(ctx, query, time.Now(), id)
+	return err
+}
+
+func (r *userRepository) UpdateLastLogin(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE users SET last_login_at = $1 WHERE id = $2`
+	_, err := r.db.Exec(ctx, query, time.Now(), id)
+	return err
+}
 
-	rows, err := r.db.QueryContext(ctx, query, tenantID, limit, offset)
+func (r *userRepository) Search(ctx context.Context, filter *models.UserFilter) ([]*models.User, error) {
+	users := []*models.User{}
+	err := r.Each(ctx, filter, func(user *models.User) error {
+		users = append(users, user)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	users := []*models.User{}
-	for rows.Next() {
-		user := &models.User{}
-		err := rows.Scan(
+	return users, nil
+}
+
+// Each calls fn for every matching user, newest first, without loading
+// them all into memory
+func (r *userRepository) Each(ctx context.Context, filter *models.UserFilter, fn func(user *models.User) error) error {
+	where, args := userWhere(filter)
+	query := `
+		SELECT id, tenant_id, COALESCE(email, ''), password_hash, first_name, last_name, phone, role, pvz_id, is_verified, is_active, last_login_at, created_at, updated_at
+		FROM users` + where + `
+		ORDER BY created_at DESC, id DESC`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user := &models.User{}
+			err := rows.Scan(
+				&user.ID,
+				&user.TenantID,
+				&user.Email,
+				&user.PasswordHash,
+				&user.FirstName,
+				&user.LastName,
+				&user.Phone,
+				&user.Role,
+				&user.PVZID,
+				&user.IsVerified,
+				&user.IsActive,
+				&user.LastLoginAt,
+				&user.CreatedAt,
+				&user.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+
+		return rows.Err()
+	})
+}
+
+func (r *userRepository) queryOne(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	user := &models.User{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		return q.QueryRowContext(ctx, query, args...).Scan(
 			&user.ID,
 			&user.TenantID,
 			&user.Email,
@@ -166,15 +264,93 @@ func (r *userRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, l
 			&user.LastName,
 			&user.Phone,
 			&user.Role,
+			&user.PVZID,
+			&user.IsVerified,
 			&user.IsActive,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
-		if err != nil {
-			return nil, err
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return user, err
+}
+
+// userWhere builds the search conditions. The query is matched with ILIKE,
+// which the trigram indexes on email, phone and full name serve; phones are
+// matched by digits so that formatting in the query does not matter.
+func userWhere(filter *models.UserFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	add("tenant_id = $%d", filter.TenantID)
+	if filter.Role != "" {
+		add("role = $%d", filter.Role)
+	}
+	if filter.IsActive != nil {
+		add("is_active = $%d", *filter.IsActive)
+	}
+	if filter.IsVerified != nil {
+		add("is_verified = $%d", *filter.IsVerified)
+	}
+	if filter.CreatedFrom != nil {
+		add("created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("created_at < $%d", *filter.CreatedTo)
+	}
+	if filter.LastLoginFrom != nil {
+		add("last_login_at >= $%d", *filter.LastLoginFrom)
+	}
+	if filter.LastLoginTo != nil {
+		add("last_login_at < $%d", *filter.LastLoginTo)
+	}
+
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		match := "email ILIKE $%[1]d OR (COALESCE(first_name, '') || ' ' || COALESCE(last_name, '')) ILIKE $%[1]d"
+		if digits := phoneDigits(query); digits != "" {
+			args = append(args, likePattern(digits))
+			match += fmt.Sprintf(" OR phone LIKE $%d", len(args))
 		}
-		users = append(users, user)
+		add("("+match+")", likePattern(query))
 	}
 
-	return users, nil
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// likePattern matches s anywhere, with LIKE wildcards in s escaped
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+// phoneDigits returns the digits of a query that looks like a phone number,
+// such as "+7 (916) 123", or "" otherwise
+func phoneDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("+()- ", r):
+		default:
+			return ""
+		}
+	}
+	if b.Len() < 3 {
+		return ""
+	}
+	return b.String()
 }
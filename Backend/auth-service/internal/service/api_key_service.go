@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// apiKeyPrefix starts every key, so leaked keys are easy to recognise
+const apiKeyPrefix = "pk_"
+
+var (
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrInvalidAPIKey        = errors.New("invalid, revoked or expired API key")
+	ErrAPIKeyIPNotAllowed   = errors.New("API key is not allowed from this address")
+	ErrAPIKeyScopeForbidden = errors.New("API keys cannot be granted permissions the caller does not hold")
+	ErrInvalidAllowedIP     = errors.New("allowed_ips must contain IP addresses or CIDR ranges")
+	ErrInvalidAPIKeyExpiry  = errors.New("expires_at must be in the future")
+)
+
+// APIKeyService manages the API keys tenant integrations use instead of
+// user tokens. A key is "pk_<id>.<secret>": the prefix up to the dot is
+// stored in clear to find and display the key, the whole key only as a
+// SHA-256 hash.
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	audit      *AuditService
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, audit *AuditService) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		audit:      audit,
+	}
+}
+
+// Create issues a key for a tenant the caller manages. The key is returned
+// once and cannot be recovered.
+func (s *APIKeyService) Create(ctx context.Context, tenantID uuid.UUID, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	if !canManageTenant(ctx, tenantID) || tenantID == uuid.Nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	claims, _ := ClaimsFromContext(ctx)
+	granted := claims.EffectivePermissions()
+	for _, scope := range req.Scopes {
+		if !containsString(tenantPermissions, scope) {
+			return nil, ErrInvalidPermission
+		}
+		if !HasPermission(granted, scope) {
+			return nil, ErrAPIKeyScopeForbidden
+		}
+	}
+
+	allowedIPs := []string{}
+	for _, ip := range req.AllowedIPs {
+		ip = strings.TrimSpace(ip)
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return nil, ErrInvalidAllowedIP
+			}
+		}
+		allowedIPs = append(allowedIPs, ip)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	secret, err := generateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(id)
+	rawKey := prefix + "." + strings.TrimRight(secret, "=")
+
+	key := &models.APIKey{
+		TenantID:   tenantID,
+		Name:       strings.TrimSpace(req.Name),
+		Prefix:     prefix,
+		KeyHash:    hashAPIKey(rawKey),
+		Scopes:     mergePermissions(req.Scopes),
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	if actorID, err := uuid.Parse(claims.UserID); err == nil {
+		key.CreatedBy = &actorID
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.recordAPIKey(ctx, AuditActionAPIKeyCreated, tenantID, key.ID, map[string]interface{}{
+		"name":        key.Name,
+		"prefix":      key.Prefix,
+		"scopes":      key.Scopes,
+		"allowed_ips": key.AllowedIPs,
+	})
+
+	return &models.CreateAPIKeyResponse{APIKey: key, Key: rawKey}, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context, tenantID uuid.UUID) ([]*models.APIKey, error) {
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return s.apiKeyRepo.ListByTenant(ctx, tenantID)
+}
+
+// Revoke disables a key of a tenant the caller manages. Integrations using
+// it are rejected from the next request on.
+func (s *APIKeyService) Revoke(ctx context.Context, tenantID, id uuid.UUID) error {
+	if !canManageTenant(ctx, tenantID) {
+		return ErrAPIKeyNotFound
+	}
+
+	revoked, err := s.apiKeyRepo.Revoke(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	s.recordAPIKey(ctx, AuditActionAPIKeyRevoked, tenantID, id, nil)
+	return nil
+}
+
+// Authenticate checks a key presented by a client at ip and returns claims
+// standing in for an access token: the key's tenant and its scopes as
+// permissions, without a user
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey, ip string) (*Claims, error) {
+	prefix, _, ok := strings.Cut(rawKey, ".")
+	if !ok || !strings.HasPrefix(prefix, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Prefixes are unique across tenants, the key determines the tenant
+	key, err := s.apiKeyRepo.GetByPrefix(database.WithPlatformScope(ctx), prefix)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(hashAPIKey(rawKey)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(key.AllowedIPs, ip) {
+		return nil, ErrAPIKeyIPNotAllowed
+	}
+
+	if err := s.apiKeyRepo.TouchLastUsed(database.WithTenant(ctx, key.TenantID), key.ID, ip); err != nil {
+		log.Printf("Failed to record use of API key %s: %v", key.Prefix, err)
+	}
+
+	claims := &Claims{
+		TenantID:    key.TenantID.String(),
+		Type:        TokenTypeAPIKey,
+		Scope:       strings.Join(key.Scopes, " "),
+		Permissions: key.Scopes,
+		APIKeyID:    key.ID.String(),
+	}
+	claims.Subject = key.Prefix
+
+	return claims, nil
+}
+
+func (s *APIKeyService) recordAPIKey(ctx context.Context, action string, tenantID, id uuid.UUID, values map[string]interface{}) {
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     &tenantID,
+		Action:       action,
+		ResourceType: AuditResourceAPIKey,
+		ResourceID:   &id,
+		NewValues:    values,
+	})
+}
+
+// ipAllowed reports whether ip matches the allow-list. An empty list
+// allows every address.
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedAddr := net.ParseIP(entry); allowedAddr != nil && allowedAddr.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashAPIKey(rawKey string) string {
+	hash := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(hash[:])
+}
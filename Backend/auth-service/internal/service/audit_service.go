@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	AuditActionLogin                = "login"
+	AuditActionLoginFailed          = "login_failed"
+	AuditActionPasswordChanged      = "password_changed"
+	AuditActionPasswordReset        = "password_reset"
+	AuditActionUserUpdated          = "user_updated"
+	AuditActionRoleChanged          = "role_changed"
+	AuditActionUserActivated        = "user_activated"
+	AuditActionUserDeactivated      = "user_deactivated"
+	AuditActionUserUnlocked         = "user_unlocked"
+	AuditActionErasureRequested     = "erasure_requested"
+	AuditActionErasureCancelled     = "erasure_cancelled"
+	AuditActionUserAnonymized       = "user_anonymized"
+	AuditActionUsersImported        = "users_imported"
+	AuditActionInvitationCreated    = "invitation_created"
+	AuditActionInvitationRevoked    = "invitation_revoked"
+	AuditActionInvitationAccepted   = "invitation_accepted"
+	AuditActionAPIKeyCreated        = "api_key_created"
+	AuditActionAPIKeyRevoked        = "api_key_revoked"
+	AuditActionImpersonationStarted = "impersonation_started"
+	AuditActionImpersonatedRequest  = "impersonated_request"
+	AuditActionRoleCreated          = "role_created"
+	AuditActionRoleUpdated          = "role_updated"
+	AuditActionRoleDeleted          = "role_deleted"
+)
+
+// Audited resource types
+const (
+	AuditResourceUser       = "user"
+	AuditResourceRole       = "role"
+	AuditResourceUserImport = "user_import"
+	AuditResourceInvitation = "invitation"
+	AuditResourceAPIKey     = "api_key"
+	AuditResourceRequest    = "request"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+var ErrAuditAccessDenied = errors.New("audit log of another tenant")
+
+// AuditService records security-relevant actions in audit_logs and serves
+// the trail to tenant admins and super admins
+type AuditService struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record stores the entry, filling in the caller and client of the request.
+// Failures are logged rather than returned so auditing never blocks the
+// audited action.
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditEntry) {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		if entry.ActorID == nil {
+			if actorID, err := uuid.Parse(claims.UserID); err == nil {
+				entry.ActorID = &actorID
+			}
+		}
+		// Whatever happens under an impersonation token is attributed to
+		// the operator as well
+		if claims.IsImpersonated() {
+			if impersonatorID, err := uuid.Parse(claims.Act.Subject); err == nil {
+				entry.ImpersonatorID = &impersonatorID
+			}
+		}
+	}
+
+	client := ClientInfoFromContext(ctx)
+	entry.IPAddress = client.IP
+	entry.UserAgent = client.UserAgent
+
+	tenantID := uuid.Nil
+	if entry.TenantID != nil {
+		tenantID = *entry.TenantID
+	}
+
+	if err := s.auditRepo.Create(tenantScope(ctx, tenantID), entry); err != nil {
+		log.Printf("Failed to record audit entry %s %s: %v", entry.Action, entry.ResourceType, err)
+	}
+}
+
+// RecordUser stores an action on a user. For changes, only the fields that
+// differ between before and after are kept; pass the same user twice for
+// actions that change none.
+func (s *AuditService) RecordUser(ctx context.Context, action string, before, after *models.User) {
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+
+	oldValues, newValues := auditDiff(auditUserValues(before), auditUserValues(after))
+
+	s.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(subject.TenantID),
+		Action:       action,
+		ResourceType: AuditResourceUser,
+		ResourceID:   &subject.ID,
+		OldValues:    oldValues,
+		NewValues:    newValues,
+	})
+}
+
+// List returns a page of the trail and the number of matching entries.
+// Tenant admins only see their own tenant.
+func (s *AuditService) List(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, int, error) {
+	if err := s.scopeFilter(ctx, filter); err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	entries, err := s.auditRepo.List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.auditRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// Export calls fn for every matching entry, newest first
+func (s *AuditService) Export(ctx context.Context, filter *models.AuditFilter, fn func(entry *models.AuditEntry) error) error {
+	if err := s.scopeFilter(ctx, filter); err != nil {
+		return err
+	}
+
+	filter.Limit = 0
+	filter.Offset = 0
+
+	return s.auditRepo.Each(ctx, filter, fn)
+}
+
+// scopeFilter restricts tenant admins to their own tenant. Super admins see
+// every tenant and the platform events unless they filter by tenant.
+func (s *AuditService) scopeFilter(ctx context.Context, filter *models.AuditFilter) error {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return ErrAuditAccessDenied
+	}
+	if claims.Role == "super_admin" {
+		return nil
+	}
+
+	tenantID, err := uuid.Parse(claims.TenantID)
+	if err != nil {
+		return ErrAuditAccessDenied
+	}
+	if filter.TenantID != nil && *filter.TenantID != tenantID {
+		return ErrAuditAccessDenied
+	}
+
+	filter.TenantID = &tenantID
+	return nil
+}
+
+// auditUserValues is the audited view of a user. Credentials are never
+// part of the trail.
+func auditUserValues(user *models.User) map[string]interface{} {
+	if user == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"email":       user.Email,
+		"phone":       user.Phone,
+		"first_name":  user.FirstName,
+		"last_name":   user.LastName,
+		"role":        user.Role,
+		"is_active":   user.IsActive,
+		"is_verified": user.IsVerified,
+	}
+}
+
+// auditDiff drops the fields both sides agree on. A missing side keeps the
+// other one whole, as for creations and deletions.
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+
+	oldValues := map[string]interface{}{}
+	newValues := map[string]interface{}{}
+	for key, value := range after {
+		if auditValueString(before[key]) != auditValueString(value) {
+			oldValues[key] = before[key]
+			newValues[key] = value
+		}
+	}
+
+	return oldValues, newValues
+}
+
+// auditValueString compares optional and plain values alike
+func auditValueString(value interface{}) string {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// auditTenantID is the tenant_id of an entry about something owned by the
+// tenant, nil for platform identities
+func auditTenantID(tenantID uuid.UUID) *uuid.UUID {
+	if tenantID == uuid.Nil {
+		return nil
+	}
+	return &tenantID
+}
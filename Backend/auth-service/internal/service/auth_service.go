@@ -2,17 +2,21 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"auth-service/internal/config"
+	"auth-service/internal/database"
 	"auth-service/internal/models"
 	"auth-service/internal/repository"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Claims struct {
@@ -20,39 +24,234 @@ type Claims struct {
 	TenantID string `json:"tenant_id"`
 	Email    string `json:"email"`
 	Role     string `json:"role"`
+	Type     string `json:"type,omitempty"`
+	FamilyID string `json:"fid,omitempty"`
+	// SessionID ties an access token to the refresh token family of its
+	// session, so ending the session also ends its access tokens
+	SessionID string `json:"sid,omitempty"`
+
+	// Permissions are the effective permissions at issuance, see
+	// permissions.go. Tokens without them fall back to the role's defaults.
+	Permissions []string `json:"perms,omitempty"`
+	// PVZID limits staff invited for one pickup point to it
+	PVZID string `json:"pvz_id,omitempty"`
+
+	// ClientID and Scope are set on service tokens, which have no user.
+	// Scope is space-separated and mirrored in Permissions.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// APIKeyID is set on the claims standing in for an API key, it never
+	// appears in a token
+	APIKeyID string `json:"-"`
+
+	// Act is set on impersonation tokens: the subject is the impersonated
+	// user, Act the operator really making the requests (RFC 8693)
+	Act *ActorClaim `json:"act,omitempty"`
+
+	EmailVerified bool `json:"email_verified"`
+	// CheckoutBlocked is set when the tenant requires a verified email
+	// before checkout and the user has not verified it yet
+	CheckoutBlocked bool `json:"checkout_blocked,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
+// ActorClaim identifies the operator behind an impersonation token
+type ActorClaim struct {
+	Subject  string `json:"sub"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// Token types
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeService marks client_credentials tokens of machine clients
+	TokenTypeService = "service"
+	// TokenTypeAPIKey marks requests authenticated with a tenant API key
+	TokenTypeAPIKey = "api_key"
+	// TokenTypeID marks OpenID Connect ID tokens, which only tell a client
+	// who signed in and never authorize a request
+	TokenTypeID = "id"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrTenantRequired = errors.New("tenant context is required")
+	ErrInvalidToken   = errors.New("invalid or expired token")
+	ErrTokenRevoked   = errors.New("token has been revoked")
+	ErrUserExists     = errors.New("user with this email already exists")
+)
+
+const (
+	accessTokenTTL  = 24 * time.Hour
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+type claimsContextKey struct{}
+
+// ContextWithClaims returns a copy of ctx carrying validated access token claims
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the access token claims stored by ContextWithClaims
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+// tenantClaim is the tenant_id claim of a user, empty for platform-level
+// identities
+func tenantClaim(tenantID uuid.UUID) string {
+	if tenantID == uuid.Nil {
+		return ""
+	}
+	return tenantID.String()
+}
+
+// tenantScope scopes the queries of ctx to the tenant, or to the whole
+// platform for platform-level identities
+func tenantScope(ctx context.Context, tenantID uuid.UUID) context.Context {
+	if tenantID == uuid.Nil {
+		return database.WithPlatformScope(ctx)
+	}
+	return database.WithTenant(ctx, tenantID)
+}
+
+// canManageTenant reports whether the caller may administer the tenant:
+// super admins and platform-wide services manage every tenant, everyone
+// else only their own
+func canManageTenant(ctx context.Context, tenantID uuid.UUID) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return claims.IsPlatform() || claims.TenantID == tenantID.String()
+}
+
+// IsService reports whether the token was issued to a machine client
+// rather than a user
+func (c *Claims) IsService() bool {
+	return c.Type == TokenTypeService
+}
+
+// IsImpersonated reports whether an operator is acting as the user
+func (c *Claims) IsImpersonated() bool {
+	return c.Act != nil
+}
+
+// IsAPIKey reports whether the request authenticated with a tenant API key
+func (c *Claims) IsAPIKey() bool {
+	return c.Type == TokenTypeAPIKey
+}
+
+// IsClientBound reports whether a user's token was issued to an OAuth
+// client rather than to the platform's own apps
+func (c *Claims) IsClientBound() bool {
+	return !c.IsService() && c.ClientID != ""
+}
+
+// IsPlatform reports whether the caller works across tenants: super admins
+// and platform-wide services
+func (c *Claims) IsPlatform() bool {
+	return (c.Role == "super_admin" && !c.IsClientBound()) || (c.IsService() && c.TenantID == "")
+}
+
+// EffectivePermissions returns the permissions carried by the claims, or the
+// defaults of the role for tokens issued before permissions were embedded.
+// Tokens of OAuth clients never fall back to the role.
+func (c *Claims) EffectivePermissions() []string {
+	if len(c.Permissions) > 0 || c.IsClientBound() {
+		return c.Permissions
+	}
+	return RolePermissions(c.Role)
+}
+
+// clientGrant binds the tokens of a session to the OAuth client they were
+// issued to and the scopes the user granted it
+type clientGrant struct {
+	ClientID string
+	Scope    string
+}
+
+// clientGrantOf returns the grant a token is bound to, nil for first-party
+// tokens
+func clientGrantOf(claims *Claims) *clientGrant {
+	if !claims.IsClientBound() {
+		return nil
+	}
+	return &clientGrant{ClientID: claims.ClientID, Scope: claims.Scope}
+}
+
 type AuthService struct {
-	userRepo   *repository.UserRepository
-	tenantRepo *repository.TenantRepository
-	redis      *redis.Client
-	config     *config.Config
+	userRepo     repository.UserRepository
+	tenantRepo   repository.TenantRepository
+	verification *EmailVerificationService
+	mfa          *MFAService
+	roles        *RoleService
+	audit        *AuditService
+	passwords    *PasswordPolicyService
+	throttler    *LoginThrottler
+	redis        *redis.Client
+	denylist     *TokenDenylist
+	families     *RefreshFamilyStore
+	events       SecurityEventPublisher
+	keys         *KeyManager
+	config       *config.Config
 }
 
 func NewAuthService(
-	userRepo *repository.UserRepository,
-	tenantRepo *repository.TenantRepository,
+	userRepo repository.UserRepository,
+	tenantRepo repository.TenantRepository,
+	verification *EmailVerificationService,
+	mfa *MFAService,
+	roles *RoleService,
+	audit *AuditService,
+	passwords *PasswordPolicyService,
+	throttler *LoginThrottler,
 	redis *redis.Client,
+	keys *KeyManager,
 	config *config.Config,
 ) *AuthService {
 	return &AuthService{
-		userRepo:   userRepo,
-		tenantRepo: tenantRepo,
-		redis:      redis,
-		config:     config,
+		userRepo:     userRepo,
+		tenantRepo:   tenantRepo,
+		verification: verification,
+		mfa:          mfa,
+		roles:        roles,
+		audit:        audit,
+		passwords:    passwords,
+		throttler:    throttler,
+		redis:        redis,
+		denylist:     NewTokenDenylist(redis),
+		families:     NewRefreshFamilyStore(redis),
+		events:       NewSecurityEventPublisher(redis),
+		keys:         keys,
+		config:       config,
 	}
 }
 
+// Register creates a customer of the tenant. Platform identities are never
+// self-registered.
 func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest, tenantID uuid.UUID) (*models.User, error) {
-	// Check if user already exists
-	existing, _ := s.userRepo.FindByEmail(ctx, req.Email, tenantID)
+	if tenantID == uuid.Nil {
+		return nil, ErrTenantRequired
+	}
+	ctx = database.WithTenant(ctx, tenantID)
+
+	// Check if user already exists in this tenant
+	existing, err := s.userRepo.GetByEmail(ctx, tenantID, req.Email)
+	if err != nil {
+		return nil, err
+	}
 	if existing != nil {
-		return nil, errors.New("user with this email already exists")
+		return nil, ErrUserExists
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(ctx, tenantID, nil, req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +262,7 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest,
 		TenantID:     tenantID,
 		Email:        req.Email,
 		Phone:        req.Phone,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
 		Role:         "customer",
@@ -77,43 +276,217 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest,
 		return nil, err
 	}
 
-	// TODO: Send verification email
+	if err := s.passwords.Record(ctx, user); err != nil {
+		log.Printf("Failed to record password of user %s: %v", user.ID, err)
+	}
+
+	if err := s.verification.SendVerification(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
 
 	return user, nil
 }
 
+// Login authenticates a user of the tenant. With uuid.Nil it authenticates
+// a platform-level identity instead, so a super admin can only sign in
+// outside of any tenant.
 func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, tenantID uuid.UUID) (*models.LoginResponse, error) {
-	// Find user
-	user, err := s.userRepo.FindByEmail(ctx, req.Email, tenantID)
+	client := ClientInfoFromContext(ctx)
+	ctx = tenantScope(ctx, tenantID)
+
+	tenant, tenantConfig, err := s.loadTenant(ctx, tenantID)
 	if err != nil {
+		return nil, err
+	}
+
+	// Brute-force protection
+	if err := s.throttler.Check(ctx, tenant.Tier, tenantID, req.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	// Find user within the tenant
+	user, err := s.userRepo.GetByEmail(ctx, tenantID, req.Email)
+	if err != nil || user == nil {
+		s.throttler.RecordFailure(ctx, tenant.Tier, tenantID, req.Email, client.IP)
+		s.recordLoginFailure(ctx, tenantID, req.Email, nil, "unknown_account")
 		return nil, errors.New("invalid email or password")
 	}
 
 	if !user.IsActive {
+		s.recordLoginFailure(ctx, tenantID, req.Email, user, "account_deactivated")
 		return nil, errors.New("account is deactivated")
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	if !s.passwords.Verify(user, req.Password) {
+		s.throttler.RecordFailure(ctx, tenant.Tier, tenantID, req.Email, client.IP)
+		s.recordLoginFailure(ctx, tenantID, req.Email, user, "invalid_password")
 		return nil, errors.New("invalid email or password")
 	}
 
+	s.throttler.RecordSuccess(ctx, tenantID, req.Email)
+	s.passwords.Upgrade(ctx, user, req.Password)
+
+	if !user.IsVerified && tenantConfig.Auth.RequireVerifiedEmailForLogin {
+		return nil, ErrEmailNotVerified
+	}
+
+	// Expired passwords are replaced through the password reset flow
+	expired, err := s.passwords.Expired(ctx, user, &tenantConfig.PasswordPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrPasswordExpired
+	}
+
+	return s.completeLogin(ctx, user, tenantConfig)
+}
+
+// recordLoginFailure audits a rejected password login. user is nil for
+// unknown accounts.
+func (s *AuthService) recordLoginFailure(ctx context.Context, tenantID uuid.UUID, email string, user *models.User, reason string) {
+	entry := &models.AuditEntry{
+		TenantID:     auditTenantID(tenantID),
+		Action:       AuditActionLoginFailed,
+		ResourceType: AuditResourceUser,
+		NewValues: map[string]interface{}{
+			"email":  email,
+			"reason": reason,
+		},
+	}
+	if user != nil {
+		entry.ResourceID = &user.ID
+	}
+
+	s.audit.Record(ctx, entry)
+}
+
+// completeLogin runs the checks shared by all first factors and issues
+// tokens. Staff accounts with 2FA get a challenge instead.
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User, tenantConfig *models.TenantConfig) (*models.LoginResponse, error) {
+	mfaEnabled, mfaRequired, err := s.mfa.IsRequired(ctx, user, tenantConfig)
+	if err != nil {
+		return nil, err
+	}
+	if mfaRequired {
+		challenge, err := s.mfa.CreateChallenge(ctx, user, !mfaEnabled)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &MFARequiredError{Challenge: challenge}
+	}
+
+	return s.IssueTokens(ctx, user)
+}
+
+// CompleteMFALogin finishes a two-step login once the second factor has been
+// verified. Recovery codes are returned when the login also completed a
+// mandatory enrollment.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, req *models.MFALoginVerifyRequest) (*models.LoginResponse, error) {
+	client := ClientInfoFromContext(ctx)
+
+	userID, err := s.mfa.ChallengeUser(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// The challenge token does not carry the tenant, the user ID is
+	// globally unique
+	user, err := s.userRepo.GetByID(database.WithPlatformScope(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidMFAChallenge
+	}
+	ctx = tenantScope(ctx, user.TenantID)
+
+	tenant, _, err := s.loadTenant(ctx, user.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrong codes lock the account like wrong passwords, which also ends
+	// the challenges already handed out
+	if err := s.throttler.Check(ctx, tenant.Tier, user.TenantID, user.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	_, recoveryCodes, err := s.mfa.CompleteChallenge(ctx, req.MFAToken, req.Code, req.RecoveryCode)
+	if err == ErrInvalidMFACode {
+		s.throttler.RecordMFAFailure(ctx, tenant.Tier, user.TenantID, user.Email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.throttler.RecordMFASuccess(ctx, user.TenantID, user.Email)
+
+	resp, err := s.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = recoveryCodes
+
+	return resp, nil
+}
+
+// IssueTokens starts a new session for an authenticated user
+func (s *AuthService) IssueTokens(ctx context.Context, user *models.User) (*models.LoginResponse, error) {
+	return s.issueSession(ctx, user, nil)
+}
+
+// IssueClientTokens starts a session of the user for an OAuth client. The
+// access tokens name the client as audience and carry only the granted
+// scopes, the refresh tokens can only be redeemed by the client.
+func (s *AuthService) IssueClientTokens(ctx context.Context, user *models.User, clientID, scope string) (*models.LoginResponse, error) {
+	return s.issueSession(ctx, user, &clientGrant{ClientID: clientID, Scope: scope})
+}
+
+func (s *AuthService) issueSession(ctx context.Context, user *models.User, grant *clientGrant) (*models.LoginResponse, error) {
+	// Every login starts a new refresh token family
+	familyID := uuid.New().String()
+
 	// Generate tokens
-	accessToken, err := s.GenerateAccessToken(user)
+	accessToken, err := s.signAccessToken(ctx, user, familyID, grant)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.GenerateRefreshToken(user)
+	refreshToken, refreshJTI, err := s.signRefreshToken(user, familyID, grant)
 	if err != nil {
 		return nil, err
 	}
 
+	client := ClientInfoFromContext(ctx)
+	session := &models.Session{
+		ID:         familyID,
+		UserID:     user.ID.String(),
+		TenantID:   tenantClaim(user.TenantID),
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+	}
+	if grant != nil {
+		session.ClientID = grant.ClientID
+	}
+	if err := s.families.Create(ctx, session, refreshJTI, refreshTokenTTL); err != nil {
+		return nil, err
+	}
+
 	// Update last login
-	s.userRepo.UpdateLastLogin(ctx, user.ID)
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
+		log.Printf("Failed to update last login of user %s: %v", user.ID, err)
+	}
 
-	// Store refresh token in Redis
-	s.redis.Set(ctx, "refresh_token:"+user.ID.String(), refreshToken, 7*24*time.Hour)
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(user.TenantID),
+		ActorID:      &user.ID,
+		Action:       AuditActionLogin,
+		ResourceType: AuditResourceUser,
+		ResourceID:   &user.ID,
+		NewValues:    map[string]interface{}{"session_id": familyID},
+	})
 
 	return &models.LoginResponse{
 		AccessToken:  accessToken,
@@ -123,44 +496,119 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, tenan
 	}, nil
 }
 
-func (s *AuthService) GenerateAccessToken(user *models.User) (string, error) {
+// GenerateAccessToken issues an access token for the session
+func (s *AuthService) GenerateAccessToken(ctx context.Context, user *models.User, sessionID string) (string, error) {
+	return s.signAccessToken(ctx, user, sessionID, nil)
+}
+
+func (s *AuthService) signAccessToken(ctx context.Context, user *models.User, sessionID string, grant *clientGrant) (string, error) {
+	claims, err := s.accessClaims(ctx, user, sessionID, accessTokenTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if grant != nil {
+		claims.ClientID = grant.ClientID
+		claims.Scope = grant.Scope
+		claims.Audience = jwt.ClaimStrings{grant.ClientID}
+		// The client only gets the user's permissions it was granted as
+		// scopes, never the whole role
+		var permissions []string
+		for _, scope := range strings.Fields(grant.Scope) {
+			if HasPermission(claims.Permissions, scope) {
+				permissions = append(permissions, scope)
+			}
+		}
+		claims.Permissions = permissions
+	}
+
+	return s.keys.Sign(claims)
+}
+
+// GenerateImpersonationToken issues an access token that lets the actor act
+// as the user for ttl. It belongs to no session and cannot be refreshed.
+func (s *AuthService) GenerateImpersonationToken(ctx context.Context, user *models.User, actor *ActorClaim, ttl time.Duration) (string, error) {
+	claims, err := s.accessClaims(ctx, user, "", ttl)
+	if err != nil {
+		return "", err
+	}
+	claims.Act = actor
+	return s.keys.Sign(claims)
+}
+
+func (s *AuthService) accessClaims(ctx context.Context, user *models.User, sessionID string, ttl time.Duration) (*Claims, error) {
+	tenantConfig, err := s.tenantConfig(ctx, user.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.roles.PermissionsForUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
 	claims := &Claims{
-		UserID:   user.ID.String(),
-		TenantID: user.TenantID.String(),
-		Email:    user.Email,
-		Role:     user.Role,
+		UserID:          user.ID.String(),
+		TenantID:        tenantClaim(user.TenantID),
+		Email:           user.Email,
+		Role:            user.Role,
+		Type:            TokenTypeAccess,
+		SessionID:       sessionID,
+		Permissions:     permissions,
+		EmailVerified:   user.IsVerified,
+		CheckoutBlocked: !user.IsVerified && tenantConfig.Auth.RequireVerifiedEmailForCheckout,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "auth-service",
+			Issuer:    s.config.Issuer,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
+	if user.PVZID != nil {
+		claims.PVZID = user.PVZID.String()
+	}
+
+	return claims, nil
 }
 
-func (s *AuthService) GenerateRefreshToken(user *models.User) (string, error) {
+// GenerateRefreshToken issues a refresh token within the given family and
+// returns it together with its jti
+func (s *AuthService) GenerateRefreshToken(user *models.User, familyID string) (string, string, error) {
+	return s.signRefreshToken(user, familyID, nil)
+}
+
+func (s *AuthService) signRefreshToken(user *models.User, familyID string, grant *clientGrant) (string, string, error) {
 	claims := &Claims{
 		UserID:   user.ID.String(),
-		TenantID: user.TenantID.String(),
+		TenantID: tenantClaim(user.TenantID),
 		Email:    user.Email,
 		Role:     user.Role,
+		Type:     TokenTypeRefresh,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(refreshTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "auth-service",
+			Issuer:    s.config.Issuer,
 		},
 	}
+	if grant != nil {
+		claims.ClientID = grant.ClientID
+		claims.Scope = grant.Scope
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
+	signed, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+
+	return signed, claims.ID, nil
 }
 
 func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.config.JWTSecret), nil
-	})
+	// The verification key is picked by the kid header
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keys.Keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -173,6 +621,93 @@ func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
+// VerifyAccessToken checks a bearer token completely: signature, expiry,
+// that it is an access or service token and that it was not revoked.
+// Refresh and ID tokens, and tokens without a type, are rejected.
+func (s *AuthService) VerifyAccessToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil || (claims.Type != TokenTypeAccess && claims.Type != TokenTypeService) {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.IsTokenRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// loadTenant returns the tenant with its parsed settings. Unknown tenants
+// get the free tier and default settings.
+func (s *AuthService) loadTenant(ctx context.Context, tenantID uuid.UUID) (*models.Tenant, *models.TenantConfig, error) {
+	tenant, err := s.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tenant == nil {
+		return &models.Tenant{ID: tenantID, Tier: "free"}, &models.TenantConfig{}, nil
+	}
+
+	tenantConfig, err := tenant.ParseConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tenant, tenantConfig, nil
+}
+
+// tenantConfig loads the tenant's settings, using defaults for unknown tenants
+func (s *AuthService) tenantConfig(ctx context.Context, tenantID uuid.UUID) (*models.TenantConfig, error) {
+	_, tenantConfig, err := s.loadTenant(ctx, tenantID)
+	return tenantConfig, err
+}
+
+// UnlockUser lifts a login lockout. Tenant admins may only unlock users of
+// their own tenant.
+func (s *AuthService) UnlockUser(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !canManageTenant(ctx, user.TenantID) {
+		return ErrUserNotFound
+	}
+
+	if err := s.throttler.Unlock(ctx, user.TenantID, user.Email); err != nil {
+		return err
+	}
+
+	s.audit.RecordUser(ctx, AuditActionUserUnlocked, user, user)
+	return nil
+}
+
+// IsTokenRevoked checks the token against the jti denylist, the user's
+// "tokens issued before" watermark and the ended sessions
+func (s *AuthService) IsTokenRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+
+	revoked, err := s.denylist.IsRevoked(ctx, claims.ID, claims.UserID, claims.IssuedAt.Time)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	return s.denylist.IsSessionRevoked(ctx, claims.SessionID)
+}
+
+// RevokeToken denylists a single token for the rest of its lifetime
+func (s *AuthService) RevokeToken(ctx context.Context, claims *Claims) error {
+	if claims.ExpiresAt == nil {
+		return nil
+	}
+	return s.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
 func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken string) (*models.LoginResponse, error) {
 	// Validate refresh token
 	claims, err := s.ValidateToken(refreshToken)
@@ -180,39 +715,151 @@ func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken strin
 		return nil, errors.New("invalid refresh token")
 	}
 
-	// Check if refresh token is in Redis
+	if claims.Type != TokenTypeRefresh || claims.FamilyID == "" {
+		return nil, errors.New("invalid token type")
+	}
+
+	revoked, err := s.IsTokenRevoked(ctx, claims)
+	if err != nil || revoked {
+		return nil, errors.New("refresh token has been revoked")
+	}
+
+	// Get user by ID, the email may have changed or be empty
 	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	tenantID := uuid.Nil
+	if claims.TenantID != "" {
+		if tenantID, err = uuid.Parse(claims.TenantID); err != nil {
+			return nil, errors.New("invalid refresh token")
+		}
+	}
+	ctx = tenantScope(ctx, tenantID)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive || tenantClaim(user.TenantID) != claims.TenantID {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// Rotate refresh token within its family
+	// Sessions of OAuth clients stay bound to the client
+	grant := clientGrantOf(claims)
+	newRefreshToken, newJTI, err := s.signRefreshToken(user, claims.FamilyID, grant)
 	if err != nil {
 		return nil, err
 	}
 
-	storedToken, err := s.redis.Get(ctx, "refresh_token:"+userID.String()).Result()
-	if err != nil || storedToken != refreshToken {
+	rotated, err := s.families.Rotate(ctx, user.ID.String(), claims.FamilyID, claims.ID, newJTI, refreshTokenTTL)
+	if err == ErrRefreshFamilyNotFound {
 		return nil, errors.New("refresh token not found or expired")
 	}
-
-	// Get user
-	tenantID, _ := uuid.Parse(claims.TenantID)
-	user, err := s.userRepo.FindByEmail(ctx, claims.Email, tenantID)
 	if err != nil {
 		return nil, err
 	}
 
+	if !rotated {
+		// An already rotated token was presented: assume it leaked and
+		// kill the whole session, its access tokens included
+		if err := s.denylist.RevokeSession(ctx, claims.FamilyID, accessTokenTTL); err != nil {
+			return nil, err
+		}
+		if err := s.families.Revoke(ctx, claims.FamilyID); err != nil {
+			return nil, err
+		}
+
+		s.events.Publish(ctx, &SecurityEvent{
+			Type:     SecurityEventRefreshTokenReuse,
+			UserID:   claims.UserID,
+			TenantID: claims.TenantID,
+			Details: map[string]string{
+				"family_id": claims.FamilyID,
+				"jti":       claims.ID,
+			},
+		})
+
+		return nil, errors.New("refresh token reuse detected")
+	}
+
 	// Generate new access token
-	accessToken, err := s.GenerateAccessToken(user)
+	accessToken, err := s.signAccessToken(ctx, user, claims.FamilyID, grant)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.LoginResponse{
 		AccessToken:  accessToken,
-		RefreshToken: refreshToken, // Keep same refresh token
+		RefreshToken: newRefreshToken,
 		ExpiresIn:    24 * 60 * 60,
 		User:         user,
 	}, nil
 }
 
-func (s *AuthService) Logout(ctx context.Context, userID uuid.UUID) error {
-	// Remove refresh token from Redis
-	return s.redis.Del(ctx, "refresh_token:"+userID.String()).Err()
+// RefreshClientTokens rotates a refresh token of an OAuth client session.
+// Only the client the session was issued to may redeem it.
+func (s *AuthService) RefreshClientTokens(ctx context.Context, refreshToken, clientID string) (*models.LoginResponse, error) {
+	claims, err := s.ValidateToken(refreshToken)
+	if err != nil || claims.ClientID != clientID {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	session, err := s.families.Get(ctx, claims.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.ClientID != clientID {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	return s.RefreshAccessToken(ctx, refreshToken)
+}
+
+// Logout ends the session of the presented refresh token and revokes the
+// access token of the current request. Must be called behind
+// AuthMiddleware; only the caller's own refresh tokens are accepted.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	accessClaims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	claims, err := s.ValidateToken(refreshToken)
+	if err != nil || claims.Type != TokenTypeRefresh || claims.FamilyID == "" ||
+		claims.UserID == "" || claims.UserID != accessClaims.UserID {
+		return ErrInvalidToken
+	}
+
+	if err := s.RevokeToken(ctx, accessClaims); err != nil {
+		return err
+	}
+	if err := s.RevokeToken(ctx, claims); err != nil {
+		return err
+	}
+
+	// End the session: its refresh token family and every access token
+	// issued within it
+	if err := s.denylist.RevokeSession(ctx, claims.FamilyID, accessTokenTTL); err != nil {
+		return err
+	}
+	return s.families.Revoke(ctx, claims.FamilyID)
+}
+
+// LogoutAll revokes every outstanding access and refresh token of the user
+func (s *AuthService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	if err := s.denylist.RevokeAllForUser(ctx, userID.String(), refreshTokenTTL); err != nil {
+		return err
+	}
+
+	return s.families.RevokeAllForUser(ctx, userID.String())
+}
+
+func generateRandomToken(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(bytes), nil
 }
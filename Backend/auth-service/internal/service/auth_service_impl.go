@@ -1,256 +0,0 @@
-package service
-
-import (
-	"context"
-	"crypto/rand"
-	"encoding/base64"
-	"errors"
-	"fmt"
-	"time"
-
-	"auth-service/internal/config"
-	"auth-service/internal/models"
-	"auth-service/internal/repository"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
-)
-
-type AuthService interface {
-	Register(ctx context.Context, req *models.RegisterRequest, tenantID uuid.UUID) (*models.User, error)
-	Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error)
-	RefreshToken(ctx context.Context, refreshToken string) (string, string, error)
-	Logout(ctx context.Context, refreshToken string) error
-	ValidateToken(tokenString string) (*jwt.Token, error)
-	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
-}
-
-type authService struct {
-	userRepo repository.UserRepository
-	cfg      *config.Config
-}
-
-func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) AuthService {
-	return &authService{
-		userRepo: userRepo,
-		cfg:      cfg,
-	}
-}
-
-func (s *authService) Register(ctx context.Context, req *models.RegisterRequest, tenantID uuid.UUID) (*models.User, error) {
-	// Check if user already exists
-	existing, err := s.userRepo.GetByEmail(ctx, req.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check existing user: %w", err)
-	}
-	if existing != nil {
-		return nil, errors.New("user with this email already exists")
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	// Create user
-	user := &models.User{
-		TenantID:     tenantID,
-		Email:        req.Email,
-		PasswordHash: string(hashedPassword),
-		FirstName:    req.FirstName,
-		LastName:     req.LastName,
-		Phone:        req.Phone,
-		Role:         "customer",
-		IsActive:     true,
-		IsVerified:   false,
-	}
-
-	if err := s.userRepo.Create(ctx, user); err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
-	}
-
-	// Clear password hash before returning
-	user.PasswordHash = ""
-
-	return user, nil
-}
-
-func (s *authService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
-	// Get user by email
-	user, err := s.userRepo.GetByEmail(ctx, req.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-	if user == nil {
-		return nil, errors.New("invalid credentials")
-	}
-
-	// Check if user is active
-	if !user.IsActive {
-		return nil, errors.New("account is disabled")
-	}
-
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
-	}
-
-	// Generate tokens
-	accessToken, err := s.generateAccessToken(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
-	}
-
-	refreshToken, err := s.generateRefreshToken(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
-	}
-
-	// Clear password hash
-	user.PasswordHash = ""
-
-	return &models.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    int64(s.cfg.JWTExpiration.Seconds()),
-		User:         user,
-	}, nil
-}
-
-func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (string, string, error) {
-	// Parse and validate refresh token
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return "", "", errors.New("invalid refresh token")
-	}
-
-	// Extract claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", "", errors.New("invalid token claims")
-	}
-
-	// Check token type
-	tokenType, ok := claims["type"].(string)
-	if !ok || tokenType != "refresh" {
-		return "", "", errors.New("invalid token type")
-	}
-
-	// Get user ID
-	userIDStr, ok := claims["user_id"].(string)
-	if !ok {
-		return "", "", errors.New("invalid user ID in token")
-	}
-
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		return "", "", errors.New("invalid user ID format")
-	}
-
-	// Get user
-	user, err := s.userRepo.GetByID(ctx, userID)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to get user: %w", err)
-	}
-	if user == nil {
-		return "", "", errors.New("user not found")
-	}
-
-	// Generate new tokens
-	newAccessToken, err := s.generateAccessToken(user)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
-	}
-
-	newRefreshToken, err := s.generateRefreshToken(user)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
-	}
-
-	return newAccessToken, newRefreshToken, nil
-}
-
-func (s *authService) Logout(ctx context.Context, refreshToken string) error {
-	// In a production system, you would invalidate the refresh token
-	// by adding it to a blacklist in Redis or database
-	// For now, we'll just return success
-	return nil
-}
-
-func (s *authService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.Valid {
-		return nil, errors.New("invalid token")
-	}
-
-	return token, nil
-}
-
-func (s *authService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
-	user, err := s.userRepo.GetByID(ctx, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-	if user == nil {
-		return nil, errors.New("user not found")
-	}
-
-	// Clear password hash
-	user.PasswordHash = ""
-
-	return user, nil
-}
-
-func (s *authService) generateAccessToken(user *models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":   user.ID.String(),
-		"tenant_id": user.TenantID.String(),
-		"email":     user.Email,
-		"role":      user.Role,
-		"type":      "access",
-		"exp":       time.Now().Add(s.cfg.JWTExpiration).Unix(),
-		"iat":       time.Now().Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.JWTSecret))
-}
-
-func (s *authService) generateRefreshToken(user *models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":   user.ID.String(),
-		"tenant_id": user.TenantID.String(),
-		"type":      "refresh",
-		"exp":       time.Now().Add(s.cfg.RefreshTokenExpiration).Unix(),
-		"iat":       time.Now().Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.JWTSecret))
-}
-
-func generateRandomToken(length int) (string, error) {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
-}
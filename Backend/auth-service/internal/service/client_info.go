@@ -0,0 +1,26 @@
+package service
+
+import "context"
+
+// ClientInfo describes the client that issued the current request
+type ClientInfo struct {
+	IP         string
+	UserAgent  string
+	DeviceName string // optional, sent by apps in X-Device-Name
+}
+
+type clientInfoContextKey struct{}
+
+// ContextWithClientInfo returns a copy of ctx carrying client information
+func ContextWithClientInfo(ctx context.Context, info *ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoContextKey{}, info)
+}
+
+// ClientInfoFromContext returns the client information of the request, or
+// an empty value outside of HTTP requests
+func ClientInfoFromContext(ctx context.Context) *ClientInfo {
+	if info, ok := ctx.Value(clientInfoContextKey{}).(*ClientInfo); ok {
+		return info
+	}
+	return &ClientInfo{}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/database"
+	"auth-service/internal/mail"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	emailVerificationPrefix         = "email_verification:"
+	emailVerificationUserPrefix     = "email_verification_user:"
+	emailVerificationCooldownPrefix = "email_verification_cooldown:"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+)
+
+// VerificationCooldownError is returned when a verification email was sent
+// too recently
+type VerificationCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *VerificationCooldownError) Error() string {
+	return fmt.Sprintf("verification email already sent, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// emailVerification is the server-side state of a verification token. The
+// token only verifies the address it was sent to.
+type emailVerification struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+}
+
+// EmailVerificationService issues and consumes email verification tokens.
+// Only the latest token of a user is valid.
+type EmailVerificationService struct {
+	userRepo repository.UserRepository
+	redis    *redis.Client
+	mailer   mail.Sender
+	config   *config.Config
+}
+
+func NewEmailVerificationService(
+	userRepo repository.UserRepository,
+	redis *redis.Client,
+	mailer mail.Sender,
+	config *config.Config,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo: userRepo,
+		redis:    redis,
+		mailer:   mailer,
+		config:   config,
+	}
+}
+
+// SendVerification issues a new verification token, replacing the previous
+// one, and emails the link
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	data, err := json.Marshal(&emailVerification{UserID: user.ID, Email: user.Email})
+	if err != nil {
+		return err
+	}
+
+	userKey := emailVerificationUserPrefix + user.ID.String()
+	previous, err := s.redis.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to load verification token: %w", err)
+	}
+
+	tokenKey := emailVerificationKey(token)
+	pipe := s.redis.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, previous)
+	}
+	pipe.Set(ctx, tokenKey, data, s.config.EmailVerificationTTL)
+	pipe.Set(ctx, userKey, tokenKey, s.config.EmailVerificationTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	s.redis.Set(ctx, s.cooldownKey(user.TenantID, user.Email), 1, s.config.EmailVerificationCooldown)
+
+	msg := &mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Welcome!\n\nPlease confirm your email address by following the link below:\n%s\n\n"+
+				"The link expires in %s.\n",
+			linkWithToken(s.config.EmailVerificationURL, token), s.config.EmailVerificationTTL,
+		),
+	}
+
+	go func() {
+		if err := s.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Resend sends a new verification email subject to a cooldown. Unknown and
+// already verified addresses are silently ignored.
+func (s *EmailVerificationService) Resend(ctx context.Context, tenantID uuid.UUID, email string) error {
+	// The cooldown applies to the address regardless of whether it exists
+	cooldownKey := s.cooldownKey(tenantID, email)
+	ok, err := s.redis.SetNX(ctx, cooldownKey, 1, s.config.EmailVerificationCooldown).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ttl, err := s.redis.TTL(ctx, cooldownKey).Result()
+		if err != nil || ttl < 0 {
+			ttl = s.config.EmailVerificationCooldown
+		}
+		return &VerificationCooldownError{RetryAfter: ttl}
+	}
+
+	user, err := s.userRepo.GetByEmail(tenantScope(ctx, tenantID), tenantID, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.IsVerified || !user.IsActive {
+		return nil
+	}
+
+	return s.SendVerification(ctx, user)
+}
+
+// Verify consumes the token and marks the user's email as verified, as
+// long as it is still the address the token was sent to
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) (*models.User, error) {
+	data, err := s.redis.GetDel(ctx, emailVerificationKey(token)).Result()
+	if err == redis.Nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume verification token: %w", err)
+	}
+
+	var verification emailVerification
+	if err := json.Unmarshal([]byte(data), &verification); err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// The token does not carry the tenant, the user ID is globally unique
+	user, err := s.userRepo.GetByID(database.WithPlatformScope(ctx), verification.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.Email == "" || !strings.EqualFold(user.Email, verification.Email) {
+		return nil, ErrInvalidVerificationToken
+	}
+	ctx = tenantScope(ctx, user.TenantID)
+
+	if !user.IsVerified {
+		if err := s.userRepo.MarkVerified(ctx, user.ID); err != nil {
+			return nil, fmt.Errorf("failed to verify email: %w", err)
+		}
+		user.IsVerified = true
+	}
+
+	user.PasswordHash = ""
+
+	return user, nil
+}
+
+func (s *EmailVerificationService) cooldownKey(tenantID uuid.UUID, email string) string {
+	return emailVerificationCooldownPrefix + tenantID.String() + ":" + strings.ToLower(email)
+}
+
+func emailVerificationKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return emailVerificationPrefix + hex.EncodeToString(hash[:])
+}
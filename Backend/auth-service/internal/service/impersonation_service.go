@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImpersonationForbidden = errors.New("not allowed while impersonating a user")
+	ErrCannotImpersonate      = errors.New("this user cannot be impersonated")
+)
+
+// ImpersonationService lets support staff see the platform as a user sees
+// it. Super admins may act as any tenant user, tenant admins as the
+// customers and staff of their own tenant below tenant_admin. Tokens carry
+// the operator in the act claim, are short-lived and every use is audited.
+type ImpersonationService struct {
+	userRepo    repository.UserRepository
+	roles       *RoleService
+	authService *AuthService
+	audit       *AuditService
+	config      *config.Config
+}
+
+func NewImpersonationService(
+	userRepo repository.UserRepository,
+	roles *RoleService,
+	authService *AuthService,
+	audit *AuditService,
+	config *config.Config,
+) *ImpersonationService {
+	return &ImpersonationService{
+		userRepo:    userRepo,
+		roles:       roles,
+		authService: authService,
+		audit:       audit,
+		config:      config,
+	}
+}
+
+// Impersonate issues a token acting as the user for ImpersonationTTL. The
+// reason is kept in the audit trail.
+func (s *ImpersonationService) Impersonate(ctx context.Context, userID uuid.UUID, reason string) (*models.ImpersonationResponse, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	// The act claim names the operator, API keys and services have none
+	if claims.IsAPIKey() || claims.IsService() || claims.UserID == "" {
+		return nil, ErrImpersonationForbidden
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !canManageTenant(ctx, user.TenantID) {
+		return nil, ErrUserNotFound
+	}
+
+	if user.IsPlatformUser() || !user.IsActive || user.ID.String() == claims.UserID {
+		return nil, ErrCannotImpersonate
+	}
+	if user.Role == "tenant_admin" && claims.Role != "super_admin" {
+		return nil, ErrCannotImpersonate
+	}
+
+	// Acting as a user must not grant the caller anything they lack, custom
+	// roles of the target included
+	targetPermissions, err := s.roles.PermissionsForUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	granted := claims.EffectivePermissions()
+	for _, permission := range targetPermissions {
+		if !HasPermission(granted, permission) {
+			return nil, ErrCannotImpersonate
+		}
+	}
+
+	actor := &ActorClaim{
+		Subject:  claims.UserID,
+		TenantID: claims.TenantID,
+		Role:     claims.Role,
+	}
+
+	ttl := s.config.ImpersonationTTL
+	accessToken, err := s.authService.GenerateImpersonationToken(ctx, user, actor, ttl)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(user.TenantID),
+		Action:       AuditActionImpersonationStarted,
+		ResourceType: AuditResourceUser,
+		ResourceID:   &user.ID,
+		NewValues: map[string]interface{}{
+			"reason":     strings.TrimSpace(reason),
+			"role":       user.Role,
+			"expires_at": expiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+
+	return &models.ImpersonationResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(ttl.Seconds()),
+		ExpiresAt:   expiresAt,
+		User:        user,
+	}, nil
+}
+
+// RecordRequest audits a request made with an impersonation token
+func (s *ImpersonationService) RecordRequest(ctx context.Context, method, path string, status int) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || !claims.IsImpersonated() {
+		return
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return
+	}
+	tenantID := uuid.Nil
+	if claims.TenantID != "" {
+		tenantID, _ = uuid.Parse(claims.TenantID)
+	}
+
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(tenantID),
+		Action:       AuditActionImpersonatedRequest,
+		ResourceType: AuditResourceRequest,
+		ResourceID:   &userID,
+		NewValues: map[string]interface{}{
+			"method": method,
+			"path":   path,
+			"status": status,
+		},
+	})
+}
+
+// rejectImpersonation guards actions an operator must not take on a
+// user's behalf, such as changing their password or role
+func rejectImpersonation(ctx context.Context) error {
+	if claims, ok := ClaimsFromContext(ctx); ok && claims.IsImpersonated() {
+		return ErrImpersonationForbidden
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/database"
+	"auth-service/internal/mail"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+	"auth-service/internal/sms"
+
+	"github.com/google/uuid"
+)
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+)
+
+var (
+	ErrInvitationNotFound        = errors.New("invitation not found")
+	ErrInvalidInvitation         = errors.New("invalid or expired invitation")
+	ErrInvitationContactRequired = errors.New("email or phone is required")
+	ErrInvitationUserExists      = errors.New("a user with this email or phone already exists")
+	ErrInvitationRoleForbidden   = errors.New("inviting staff roles requires the roles:manage permission")
+	ErrPVZNotFound               = errors.New("pickup point not found")
+	ErrPVZScopeNotAllowed        = errors.New("customers cannot be limited to a pickup point")
+)
+
+// InvitationService onboards staff: an admin invites an email or phone
+// into a role, and the invitee registers through a signed link that expires
+// after InvitationTTL. The link carries the invitation ID and an HMAC over
+// it, nothing secret is stored.
+type InvitationService struct {
+	invitationRepo repository.InvitationRepository
+	userRepo       repository.UserRepository
+	authService    *AuthService
+	passwords      *PasswordPolicyService
+	audit          *AuditService
+	mailer         mail.Sender
+	sms            sms.Sender
+	config         *config.Config
+}
+
+func NewInvitationService(
+	invitationRepo repository.InvitationRepository,
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	passwords *PasswordPolicyService,
+	audit *AuditService,
+	mailer mail.Sender,
+	smsSender sms.Sender,
+	config *config.Config,
+) *InvitationService {
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		authService:    authService,
+		passwords:      passwords,
+		audit:          audit,
+		mailer:         mailer,
+		sms:            smsSender,
+		config:         config,
+	}
+}
+
+// Create invites an email or phone into a tenant the caller manages and
+// sends the link. Earlier pending invitations to the same contact are
+// revoked.
+func (s *InvitationService) Create(ctx context.Context, tenantID uuid.UUID, req *models.CreateInvitationRequest) (*models.CreateInvitationResponse, error) {
+	if !canManageTenant(ctx, tenantID) || tenantID == uuid.Nil {
+		return nil, ErrInvitationNotFound
+	}
+
+	if !assignableRoles[req.Role] {
+		return nil, ErrRoleNotAssignable
+	}
+	claims, _ := ClaimsFromContext(ctx)
+	if req.Role != "customer" && !HasPermission(claims.EffectivePermissions(), PermissionRolesManage) {
+		return nil, ErrInvitationRoleForbidden
+	}
+	if !callerHolds(ctx, RolePermissions(req.Role)) {
+		return nil, ErrPermissionNotHeld
+	}
+
+	invitation := &models.Invitation{
+		TenantID:  tenantID,
+		Role:      req.Role,
+		Status:    InvitationStatusPending,
+		ExpiresAt: time.Now().Add(s.config.InvitationTTL),
+	}
+
+	if req.Email != nil && strings.TrimSpace(*req.Email) != "" {
+		email := strings.TrimSpace(*req.Email)
+		invitation.Email = &email
+	}
+	if req.Phone != nil && strings.TrimSpace(*req.Phone) != "" {
+		phone, err := normalizePhone(*req.Phone)
+		if err != nil {
+			return nil, err
+		}
+		invitation.Phone = &phone
+	}
+	if invitation.Email == nil && invitation.Phone == nil {
+		return nil, ErrInvitationContactRequired
+	}
+
+	if req.PVZID != nil {
+		if req.Role == "customer" {
+			return nil, ErrPVZScopeNotAllowed
+		}
+		exists, err := s.invitationRepo.PVZExists(ctx, tenantID, *req.PVZID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrPVZNotFound
+		}
+		invitation.PVZID = req.PVZID
+	}
+
+	if err := s.checkNotRegistered(ctx, tenantID, invitation.Email, invitation.Phone); err != nil {
+		return nil, err
+	}
+
+	if actorID, err := uuid.Parse(claims.UserID); err == nil {
+		invitation.InvitedBy = &actorID
+	}
+
+	if err := s.invitationRepo.RevokePending(ctx, tenantID, invitation.Email, invitation.Phone); err != nil {
+		return nil, err
+	}
+	if err := s.invitationRepo.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	link := linkWithToken(s.config.InvitationURL, s.signInvitation(invitation))
+	if err := s.send(ctx, invitation, link); err != nil {
+		// The admin gets the link in the response and can pass it on
+		log.Printf("Failed to send invitation %s: %v", invitation.ID, err)
+	}
+
+	s.recordInvitation(ctx, AuditActionInvitationCreated, invitation, nil)
+
+	return &models.CreateInvitationResponse{Invitation: invitation, InviteURL: link}, nil
+}
+
+// List returns the invitations of a tenant the caller manages, optionally
+// only those with the given status
+func (s *InvitationService) List(ctx context.Context, tenantID uuid.UUID, status string) ([]*models.Invitation, error) {
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrInvitationNotFound
+	}
+	return s.invitationRepo.ListByTenant(ctx, tenantID, status)
+}
+
+// Revoke withdraws a pending invitation of a tenant the caller manages
+func (s *InvitationService) Revoke(ctx context.Context, tenantID, id uuid.UUID) error {
+	if !canManageTenant(ctx, tenantID) {
+		return ErrInvitationNotFound
+	}
+
+	invitation, err := s.invitationRepo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if invitation == nil {
+		return ErrInvitationNotFound
+	}
+
+	revoked, err := s.invitationRepo.Revoke(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrInvitationNotFound
+	}
+
+	s.recordInvitation(ctx, AuditActionInvitationRevoked, invitation, nil)
+	return nil
+}
+
+// Lookup returns the pending invitation behind a link, for the accept page
+// to show what the invitee is signing up for
+func (s *InvitationService) Lookup(ctx context.Context, tenantID uuid.UUID, token string) (*models.Invitation, error) {
+	return s.verify(database.WithTenant(ctx, tenantID), tenantID, token)
+}
+
+// Accept registers the invitee with the invited role and pickup point and
+// signs them in. Accounts of email invitations start verified: following
+// the link proves the address.
+func (s *InvitationService) Accept(ctx context.Context, tenantID uuid.UUID, req *models.AcceptInvitationRequest) (*models.LoginResponse, error) {
+	ctx = database.WithTenant(ctx, tenantID)
+
+	invitation, err := s.verify(ctx, tenantID, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Invitations sent by SMS let the invitee add an email
+	email := strings.TrimSpace(req.Email)
+	if invitation.Email != nil {
+		email = *invitation.Email
+	}
+
+	var emailPtr *string
+	if email != "" {
+		emailPtr = &email
+	}
+	if err := s.checkNotRegistered(ctx, tenantID, emailPtr, invitation.Phone); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.passwords.Hash(ctx, tenantID, nil, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		TenantID:     tenantID,
+		Email:        email,
+		Phone:        invitation.Phone,
+		PasswordHash: hashedPassword,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		Role:         invitation.Role,
+		PVZID:        invitation.PVZID,
+		IsVerified:   invitation.Email != nil,
+		IsActive:     true,
+	}
+
+	accepted, err := s.invitationRepo.Accept(ctx, invitation, user)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvalidInvitation
+	}
+
+	if err := s.passwords.Record(ctx, user); err != nil {
+		log.Printf("Failed to record password of user %s: %v", user.ID, err)
+	}
+
+	invitation.AcceptedUserID = &user.ID
+	s.recordInvitation(ctx, AuditActionInvitationAccepted, invitation, &user.ID)
+
+	return s.authService.IssueTokens(ctx, user)
+}
+
+// verify checks the signature of the token and returns its invitation if
+// it can still be accepted
+func (s *InvitationService) verify(ctx context.Context, tenantID uuid.UUID, token string) (*models.Invitation, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidInvitation
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation, err := s.invitationRepo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || !hmac.Equal([]byte(token), []byte(s.signInvitation(invitation))) {
+		return nil, ErrInvalidInvitation
+	}
+	if invitation.Status != InvitationStatusPending || time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+
+	return invitation, nil
+}
+
+// signInvitation returns the token of the invitation link. The signature
+// covers the tenant and expiry, so neither can be changed.
+func (s *InvitationService) signInvitation(invitation *models.Invitation) string {
+	mac := hmac.New(sha256.New, []byte(s.config.InvitationSigningKey))
+	mac.Write(invitation.ID[:])
+	mac.Write(invitation.TenantID[:])
+	mac.Write([]byte(strconv.FormatInt(invitation.ExpiresAt.Unix(), 10)))
+
+	return base64.RawURLEncoding.EncodeToString(invitation.ID[:]) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *InvitationService) send(ctx context.Context, invitation *models.Invitation, link string) error {
+	if invitation.Email != nil {
+		return s.mailer.Send(ctx, &mail.Message{
+			To:      *invitation.Email,
+			Subject: "You have been invited",
+			Body: fmt.Sprintf(
+				"You have been invited to join as %s.\n\n"+
+					"Follow the link below to create your account:\n%s\n\n"+
+					"The link expires in %s.\n",
+				invitation.Role, link, s.config.InvitationTTL,
+			),
+		})
+	}
+
+	return s.sms.Send(ctx, &sms.Message{
+		To:   *invitation.Phone,
+		Body: fmt.Sprintf("You have been invited to join as %s: %s", invitation.Role, link),
+	})
+}
+
+func (s *InvitationService) checkNotRegistered(ctx context.Context, tenantID uuid.UUID, email, phone *string) error {
+	if email != nil {
+		user, err := s.userRepo.GetByEmail(ctx, tenantID, *email)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			return ErrInvitationUserExists
+		}
+	}
+	if phone != nil {
+		user, err := s.userRepo.FindByPhone(ctx, tenantID, *phone)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			return ErrInvitationUserExists
+		}
+	}
+	return nil
+}
+
+func (s *InvitationService) recordInvitation(ctx context.Context, action string, invitation *models.Invitation, actorID *uuid.UUID) {
+	values := map[string]interface{}{"role": invitation.Role}
+	if invitation.PVZID != nil {
+		values["pvz_id"] = invitation.PVZID.String()
+	}
+	if invitation.AcceptedUserID != nil {
+		values["user_id"] = invitation.AcceptedUserID.String()
+	}
+
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     &invitation.TenantID,
+		ActorID:      actorID,
+		Action:       action,
+		ResourceType: AuditResourceInvitation,
+		ResourceID:   &invitation.ID,
+		NewValues:    values,
+	})
+}
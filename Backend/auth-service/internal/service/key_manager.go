@@ -0,0 +1,452 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"auth-service/internal/config"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	signingKeysKey     = "jwt_signing_keys"
+	signingKeysLockKey = "jwt_signing_keys:lock"
+
+	// New keys are published in JWKS this long before they start signing,
+	// so verifiers with cached key sets pick them up in time
+	keyPublishLead = time.Hour
+	keyRefreshRate = time.Minute
+)
+
+// Signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is a single asymmetric key pair identified by kid
+type SigningKey struct {
+	ID          string    `json:"kid"`
+	Algorithm   string    `json:"alg"`
+	PrivateKey  string    `json:"private_key"` // PKCS#8 PEM, encrypted, see KeyManager
+	ActivatesAt time.Time `json:"activates_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+
+	signer crypto.Signer
+}
+
+// JWK is the public part of a signing key in RFC 7517 format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager owns the JWT signing keys. Keys are shared between replicas
+// through Redis and rotated on a schedule; retired keys stay available for
+// verification until every token they signed has expired.
+//
+// Other services share the Redis, so private keys are stored encrypted with
+// JWT_KEY_ENCRYPTION_KEY, which only auth-service holds. Verifiers get the
+// public keys from JWKS.
+type KeyManager struct {
+	redis  *redis.Client
+	aead   cipher.AEAD
+	config *config.Config
+
+	mu   sync.RWMutex
+	keys map[string]*SigningKey
+}
+
+func NewKeyManager(redis *redis.Client, config *config.Config) (*KeyManager, error) {
+	if config.JWTSigningAlgorithm != AlgorithmRS256 && config.JWTSigningAlgorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm: %s", config.JWTSigningAlgorithm)
+	}
+
+	secret := sha256.Sum256([]byte(config.JWTKeyEncryptionKey))
+	block, err := aes.NewCipher(secret[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &KeyManager{
+		redis:  redis,
+		aead:   aead,
+		config: config,
+		keys:   map[string]*SigningKey{},
+	}
+
+	// Another replica may be generating the first key right now
+	for attempt := 0; attempt < 5; attempt++ {
+		if err = m.Rotate(context.Background()); err == nil {
+			return m, nil
+		}
+		time.Sleep(time.Second)
+	}
+
+	return nil, err
+}
+
+// Start reloads and rotates keys periodically until ctx is cancelled
+func (m *KeyManager) Start(ctx context.Context) {
+	ticker := time.NewTicker(keyRefreshRate)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Rotate(ctx); err != nil {
+					log.Printf("Failed to rotate JWT signing keys: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Rotate loads the shared key set, drops expired keys and generates the
+// next key when the current one is due for rotation
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	keys, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	interval := m.config.JWTKeyRotationInterval
+
+	var expired []string
+	var newest *SigningKey
+	for kid, key := range keys {
+		if now.After(key.ExpiresAt) {
+			expired = append(expired, kid)
+			delete(keys, kid)
+			continue
+		}
+		if newest == nil || key.ActivatesAt.After(newest.ActivatesAt) {
+			newest = key
+		}
+	}
+
+	if len(expired) > 0 {
+		if err := m.redis.HDel(ctx, signingKeysKey, expired...).Err(); err != nil {
+			return err
+		}
+	}
+
+	var activatesAt time.Time
+	switch {
+	case newest == nil:
+		activatesAt = now
+	case !now.Before(newest.ActivatesAt.Add(interval - keyPublishLead)):
+		activatesAt = newest.ActivatesAt.Add(interval)
+		if activatesAt.Before(now) {
+			activatesAt = now
+		}
+	}
+
+	if !activatesAt.IsZero() {
+		// Only one replica generates the next key
+		locked, err := m.redis.SetNX(ctx, signingKeysLockKey, 1, 30*time.Second).Result()
+		if err != nil {
+			return err
+		}
+		if locked {
+			key, err := m.generate(ctx, activatesAt)
+			if err != nil {
+				return err
+			}
+			keys[key.ID] = key
+			log.Printf("🔑 Generated JWT signing key %s (%s), active from %s", key.ID, key.Algorithm, activatesAt.Format(time.RFC3339))
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+
+	if len(keys) == 0 {
+		return errors.New("no JWT signing keys available")
+	}
+
+	return nil
+}
+
+// SigningKey returns the newest key that is already active
+func (m *KeyManager) SigningKey() (*SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var current *SigningKey
+	for _, key := range m.keys {
+		if key.ActivatesAt.After(now) {
+			continue
+		}
+		if current == nil || key.ActivatesAt.After(current.ActivatesAt) {
+			current = key
+		}
+	}
+
+	if current == nil {
+		return nil, errors.New("no active JWT signing key")
+	}
+
+	return current, nil
+}
+
+// Sign signs the claims with the current key and sets the kid header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	key, err := m.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signer)
+}
+
+// Keyfunc resolves the verification key by the kid header and rejects
+// tokens whose alg does not match the key
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if m.config.JWTAcceptLegacyHS256 {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+				return []byte(m.config.JWTSecret), nil
+			}
+		}
+		return nil, errors.New("token has no kid header")
+	}
+
+	m.mu.RLock()
+	key, ok := m.keys[kid]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.signer.Public(), nil
+}
+
+// JWKS returns the public keys of all published keys
+func (m *KeyManager) JWKS() *JWKSet {
+	m.mu.RLock()
+	keys := make([]*SigningKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.After(keys[j].ActivatesAt)
+	})
+
+	set := &JWKSet{Keys: []JWK{}}
+	for _, key := range keys {
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Algorithm,
+		}
+
+		switch pub := key.signer.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// SupportedAlgorithms lists the algorithms tokens may be signed with
+func (m *KeyManager) SupportedAlgorithms() []string {
+	return []string{m.config.JWTSigningAlgorithm}
+}
+
+func (m *KeyManager) load(ctx context.Context) (map[string]*SigningKey, error) {
+	raw, err := m.redis.HGetAll(ctx, signingKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*SigningKey{}
+	for kid, data := range raw {
+		key := &SigningKey{}
+		if err := json.Unmarshal([]byte(data), key); err != nil {
+			return nil, fmt.Errorf("failed to decode signing key %s: %w", kid, err)
+		}
+
+		// Keys that do not decrypt were not written by us, never sign or
+		// verify with them
+		pemData, err := m.decryptPrivateKey(kid, key.PrivateKey)
+		if err != nil {
+			log.Printf("Skipping JWT signing key %s: %v", kid, err)
+			continue
+		}
+
+		signer, err := parsePrivateKey(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key %s: %w", kid, err)
+		}
+		key.signer = signer
+
+		keys[kid] = key
+	}
+
+	return keys, nil
+}
+
+func (m *KeyManager) generate(ctx context.Context, activatesAt time.Time) (*SigningKey, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch m.config.JWTSigningAlgorithm {
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := uuid.New().String()
+	encrypted, err := m.encryptPrivateKey(kid, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		ID:          kid,
+		Algorithm:   m.config.JWTSigningAlgorithm,
+		PrivateKey:  encrypted,
+		ActivatesAt: activatesAt,
+		ExpiresAt:   activatesAt.Add(m.config.JWTKeyRotationInterval + m.config.JWTKeyRetention),
+		signer:      signer,
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.redis.HSet(ctx, signingKeysKey, key.ID, data).Err(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// encryptPrivateKey seals the PEM with AES-GCM, bound to the kid so a key
+// cannot be stored under another kid
+func (m *KeyManager) encryptPrivateKey(kid, pemData string) (string, error) {
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := m.aead.Seal(nonce, nonce, []byte(pemData), []byte(kid))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (m *KeyManager) decryptPrivateKey(kid, encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", errors.New("private key is not encrypted")
+	}
+
+	nonceSize := m.aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", errors.New("invalid encrypted private key")
+	}
+
+	plaintext, err := m.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(kid))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func parsePrivateKey(data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	return signer, nil
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	loginFailuresPrefix = "login_failures:"
+	loginLockPrefix     = "login_lock:"
+)
+
+// Security event types
+const (
+	SecurityEventAccountLocked = "account_locked"
+	SecurityEventIPLocked      = "ip_locked"
+)
+
+// LoginThrottlePolicy controls brute-force protection for one tenant tier
+type LoginThrottlePolicy struct {
+	// Failures are counted in a sliding window of this length
+	Window time.Duration
+	// After this many failures every further attempt is delayed,
+	// doubling the delay each time up to MaxDelay
+	DelayAfter int
+	MaxDelay   time.Duration
+	// Failures per account before a temporary lockout
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Failures per client IP before the IP is locked out
+	IPLockoutThreshold int
+}
+
+// UnmarshalJSON accepts durations as Go duration strings ("15m")
+func (p *LoginThrottlePolicy) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Window             string `json:"window"`
+		DelayAfter         int    `json:"delay_after"`
+		MaxDelay           string `json:"max_delay"`
+		LockoutThreshold   int    `json:"lockout_threshold"`
+		LockoutDuration    string `json:"lockout_duration"`
+		IPLockoutThreshold int    `json:"ip_lockout_threshold"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var err error
+	if p.Window, err = time.ParseDuration(raw.Window); err != nil {
+		return fmt.Errorf("window: %w", err)
+	}
+	if p.MaxDelay, err = time.ParseDuration(raw.MaxDelay); err != nil {
+		return fmt.Errorf("max_delay: %w", err)
+	}
+	if p.LockoutDuration, err = time.ParseDuration(raw.LockoutDuration); err != nil {
+		return fmt.Errorf("lockout_duration: %w", err)
+	}
+	p.DelayAfter = raw.DelayAfter
+	p.LockoutThreshold = raw.LockoutThreshold
+	p.IPLockoutThreshold = raw.IPLockoutThreshold
+
+	return nil
+}
+
+var defaultLoginThrottlePolicies = map[string]LoginThrottlePolicy{
+	"free": {
+		Window: 15 * time.Minute, DelayAfter: 3, MaxDelay: 30 * time.Second,
+		LockoutThreshold: 10, LockoutDuration: 30 * time.Minute, IPLockoutThreshold: 50,
+	},
+	"starter": {
+		Window: 15 * time.Minute, DelayAfter: 3, MaxDelay: 30 * time.Second,
+		LockoutThreshold: 10, LockoutDuration: 15 * time.Minute, IPLockoutThreshold: 100,
+	},
+	"professional": {
+		Window: 15 * time.Minute, DelayAfter: 5, MaxDelay: time.Minute,
+		LockoutThreshold: 10, LockoutDuration: 15 * time.Minute, IPLockoutThreshold: 200,
+	},
+	"enterprise": {
+		Window: 30 * time.Minute, DelayAfter: 3, MaxDelay: 2 * time.Minute,
+		LockoutThreshold: 5, LockoutDuration: time.Hour, IPLockoutThreshold: 200,
+	},
+}
+
+// LoginThrottledError is returned while an account or IP may not attempt
+// to log in
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, locked for %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginThrottler keeps sliding-window failure counters per account
+// (tenant + email) and per client IP in Redis
+type LoginThrottler struct {
+	redis    *redis.Client
+	events   SecurityEventPublisher
+	policies map[string]LoginThrottlePolicy
+}
+
+// NewLoginThrottler builds a throttler from the default tier policies,
+// overridden by a JSON object of tier -> policy when overrides is not empty,
+// e.g. {"enterprise": {"window": "30m", "delay_after": 3, "max_delay": "2m",
+// "lockout_threshold": 5, "lockout_duration": "1h", "ip_lockout_threshold": 200}}
+func NewLoginThrottler(redis *redis.Client, events SecurityEventPublisher, overrides string) (*LoginThrottler, error) {
+	policies := map[string]LoginThrottlePolicy{}
+	for tier, policy := range defaultLoginThrottlePolicies {
+		policies[tier] = policy
+	}
+
+	if overrides != "" {
+		var custom map[string]LoginThrottlePolicy
+		if err := json.Unmarshal([]byte(overrides), &custom); err != nil {
+			return nil, fmt.Errorf("invalid login throttle policies: %w", err)
+		}
+		for tier, policy := range custom {
+			policies[tier] = policy
+		}
+	}
+
+	return &LoginThrottler{
+		redis:    redis,
+		events:   events,
+		policies: policies,
+	}, nil
+}
+
+// Check returns a *LoginThrottledError if the attempt must be rejected
+func (t *LoginThrottler) Check(ctx context.Context, tier string, tenantID uuid.UUID, email, ip string) error {
+	policy := t.policy(tier)
+	accountKey := accountThrottleKey(tenantID, email)
+
+	for _, lockKey := range []string{loginLockPrefix + accountKey, loginLockPrefix + ipThrottleKey(ip)} {
+		ttl, err := t.redis.PTTL(ctx, lockKey).Result()
+		if err != nil {
+			return err
+		}
+		if ttl > 0 {
+			return &LoginThrottledError{RetryAfter: ttl, Locked: true}
+		}
+	}
+
+	// Progressive delay based on recent account failures
+	now := time.Now()
+	failures, err := t.redis.ZRevRangeByScoreWithScores(ctx, loginFailuresPrefix+accountKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.Add(-policy.Window).UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	if len(failures) < policy.DelayAfter || policy.DelayAfter <= 0 {
+		return nil
+	}
+
+	delay := time.Second << uint(len(failures)-policy.DelayAfter)
+	if delay > policy.MaxDelay || delay <= 0 {
+		delay = policy.MaxDelay
+	}
+
+	lastFailure := time.UnixMilli(int64(failures[0].Score))
+	if wait := lastFailure.Add(delay).Sub(now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt and locks the account or IP once
+// the tier thresholds are reached
+func (t *LoginThrottler) RecordFailure(ctx context.Context, tier string, tenantID uuid.UUID, email, ip string) error {
+	policy := t.policy(tier)
+
+	accountKey := accountThrottleKey(tenantID, email)
+	accountFailures, err := t.addFailure(ctx, accountKey, policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if policy.LockoutThreshold > 0 && accountFailures >= int64(policy.LockoutThreshold) {
+		if err := t.lock(ctx, accountKey, policy.LockoutDuration); err != nil {
+			return err
+		}
+		t.events.Publish(ctx, &SecurityEvent{
+			Type:     SecurityEventAccountLocked,
+			TenantID: tenantID.String(),
+			Details: map[string]string{
+				"email": email,
+				"ip":    ip,
+			},
+		})
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	ipKey := ipThrottleKey(ip)
+	ipFailures, err := t.addFailure(ctx, ipKey, policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if policy.IPLockoutThreshold > 0 && ipFailures >= int64(policy.IPLockoutThreshold) {
+		if err := t.lock(ctx, ipKey, policy.LockoutDuration); err != nil {
+			return err
+		}
+		t.events.Publish(ctx, &SecurityEvent{
+			Type:     SecurityEventIPLocked,
+			TenantID: tenantID.String(),
+			Details: map[string]string{
+				"ip": ip,
+			},
+		})
+	}
+
+	return nil
+}
+
+// RecordSuccess resets the account failure counter
+func (t *LoginThrottler) RecordSuccess(ctx context.Context, tenantID uuid.UUID, email string) error {
+	return t.redis.Del(ctx, loginFailuresPrefix+accountThrottleKey(tenantID, email)).Err()
+}
+
+// RecordMFAFailure counts a wrong second factor of the account and locks
+// the account once the lockout threshold is reached. These failures are
+// counted apart from password failures, which every correct password resets,
+// so each new challenge does not bring a fresh budget of guesses.
+func (t *LoginThrottler) RecordMFAFailure(ctx context.Context, tier string, tenantID uuid.UUID, email string) error {
+	policy := t.policy(tier)
+
+	accountKey := accountThrottleKey(tenantID, email)
+	failures, err := t.addFailure(ctx, mfaThrottleKey(tenantID, email), policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if policy.LockoutThreshold > 0 && failures >= int64(policy.LockoutThreshold) {
+		if err := t.lock(ctx, accountKey, policy.LockoutDuration); err != nil {
+			return err
+		}
+		t.events.Publish(ctx, &SecurityEvent{
+			Type:     SecurityEventAccountLocked,
+			TenantID: tenantID.String(),
+			Details: map[string]string{
+				"email":  email,
+				"factor": "mfa",
+			},
+		})
+	}
+
+	return nil
+}
+
+// RecordMFASuccess resets the second factor failure counter of the account
+func (t *LoginThrottler) RecordMFASuccess(ctx context.Context, tenantID uuid.UUID, email string) error {
+	return t.redis.Del(ctx, loginFailuresPrefix+mfaThrottleKey(tenantID, email)).Err()
+}
+
+// Unlock lifts an account lockout and clears its failure counter
+func (t *LoginThrottler) Unlock(ctx context.Context, tenantID uuid.UUID, email string) error {
+	accountKey := accountThrottleKey(tenantID, email)
+	return t.redis.Del(ctx, loginLockPrefix+accountKey, loginFailuresPrefix+accountKey, loginFailuresPrefix+mfaThrottleKey(tenantID, email)).Err()
+}
+
+func (t *LoginThrottler) policy(tier string) LoginThrottlePolicy {
+	if policy, ok := t.policies[tier]; ok {
+		return policy
+	}
+	return t.policies["free"]
+}
+
+func (t *LoginThrottler) addFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now()
+	failuresKey := loginFailuresPrefix + key
+
+	pipe := t.redis.TxPipeline()
+	pipe.ZAdd(ctx, failuresKey, &redis.Z{
+		Score:  float64(now.UnixMilli()),
+		Member: uuid.New().String(),
+	})
+	pipe.ZRemRangeByScore(ctx, failuresKey, "-inf", strconv.FormatInt(now.Add(-window).UnixMilli(), 10))
+	count := pipe.ZCard(ctx, failuresKey)
+	pipe.Expire(ctx, failuresKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return count.Val(), nil
+}
+
+func (t *LoginThrottler) lock(ctx context.Context, key string, duration time.Duration) error {
+	return t.redis.Set(ctx, loginLockPrefix+key, 1, duration).Err()
+}
+
+func accountThrottleKey(tenantID uuid.UUID, email string) string {
+	return "account:" + tenantID.String() + ":" + strings.ToLower(email)
+}
+
+func mfaThrottleKey(tenantID uuid.UUID, email string) string {
+	return "mfa:" + tenantID.String() + ":" + strings.ToLower(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,552 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	mfaChallengePrefix         = "mfa_challenge:"
+	mfaChallengeAttemptsPrefix = "mfa_challenge_attempts:"
+	totpUsedPrefix             = "totp_used:"
+
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	recoveryCodeCount       = 10
+)
+
+// Security event types
+const (
+	SecurityEventMFAEnabled          = "mfa_enabled"
+	SecurityEventMFADisabled         = "mfa_disabled"
+	SecurityEventRecoveryCodeUsed    = "mfa_recovery_code_used"
+	SecurityEventMFAAttemptsExceeded = "mfa_challenge_attempts_exceeded"
+)
+
+// Staff roles may enable TOTP and can be required to use it by the tenant
+var staffRoles = map[string]bool{
+	"super_admin":  true,
+	"tenant_admin": true,
+	"vendor":       true,
+	"operator":     true,
+}
+
+var (
+	ErrMFANotAvailable      = errors.New("two-factor authentication is only available for staff accounts")
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled        = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled       = errors.New("two-factor enrollment has not been started")
+	ErrInvalidMFACode       = errors.New("invalid two-factor code")
+	ErrInvalidMFAChallenge  = errors.New("invalid or expired MFA token")
+	ErrMFAEnrollmentPending = errors.New("two-factor enrollment must be completed first")
+)
+
+// MFARequiredError is returned by Login when the password was correct but a
+// second factor is needed before tokens are issued
+type MFARequiredError struct {
+	Challenge *models.MFAChallengeResponse
+}
+
+func (e *MFARequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+// mfaChallenge is the server-side state of a pending two-step login
+type mfaChallenge struct {
+	UserID             uuid.UUID `json:"user_id"`
+	TenantID           uuid.UUID `json:"tenant_id"`
+	EnrollmentRequired bool      `json:"enrollment_required"`
+}
+
+// MFAService manages TOTP enrollment, recovery codes and login challenges
+type MFAService struct {
+	mfaRepo  repository.MFARepository
+	userRepo repository.UserRepository
+	redis    *redis.Client
+	events   SecurityEventPublisher
+	aead     cipher.AEAD
+	config   *config.Config
+}
+
+func NewMFAService(
+	mfaRepo repository.MFARepository,
+	userRepo repository.UserRepository,
+	redis *redis.Client,
+	config *config.Config,
+) (*MFAService, error) {
+	key := sha256.Sum256([]byte(config.MFAEncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		redis:    redis,
+		events:   NewSecurityEventPublisher(redis),
+		aead:     aead,
+		config:   config,
+	}, nil
+}
+
+// IsRequired reports whether the user has to pass a second factor at login,
+// either because they enabled it or because their tenant mandates it
+func (s *MFAService) IsRequired(ctx context.Context, user *models.User, tenantConfig *models.TenantConfig) (enabled bool, required bool, err error) {
+	if !staffRoles[user.Role] {
+		return false, false, nil
+	}
+
+	mfa, err := s.mfaRepo.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return false, false, err
+	}
+	enabled = mfa != nil && mfa.IsEnabled
+
+	required = enabled
+	for _, role := range tenantConfig.Auth.MFARequiredRoles {
+		if role == user.Role {
+			required = true
+		}
+	}
+
+	return enabled, required, nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user. The secret only
+// becomes active once ConfirmEnrollment succeeds with a code from it.
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID uuid.UUID) (*models.MFAEnrollResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !staffRoles[user.Role] {
+		return nil, ErrMFANotAvailable
+	}
+
+	existing, err := s.mfaRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.IsEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.Upsert(ctx, &models.UserMFA{
+		UserID:     user.ID,
+		TenantID:   user.TenantID,
+		TOTPSecret: encrypted,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totpURI(secret, s.config.MFAIssuer, user.Email),
+	}, nil
+}
+
+// ConfirmEnrollment enables TOTP after the first valid code and returns the
+// recovery codes. They are shown once and only their hashes are stored.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	mfa, err := s.mfaRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.IsEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.Enable(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, &SecurityEvent{
+		Type:     SecurityEventMFAEnabled,
+		UserID:   userID.String(),
+		TenantID: mfa.TenantID.String(),
+	})
+
+	return codes, nil
+}
+
+// Disable turns TOTP off. A current code or an unused recovery code is
+// required so a stolen access token alone cannot remove the second factor.
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	if err := rejectImpersonation(ctx); err != nil {
+		return err
+	}
+	mfa, err := s.enabledMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyCodeOrRecovery(ctx, mfa, code); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	s.events.Publish(ctx, &SecurityEvent{
+		Type:     SecurityEventMFADisabled,
+		UserID:   userID.String(),
+		TenantID: mfa.TenantID.String(),
+	})
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	mfa, err := s.enabledMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.UpdateRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CreateChallenge stores a pending login and returns the opaque token the
+// client exchanges for tokens at /auth/mfa/verify
+func (s *MFAService) CreateChallenge(ctx context.Context, user *models.User, enrollmentRequired bool) (*models.MFAChallengeResponse, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&mfaChallenge{
+		UserID:             user.ID,
+		TenantID:           user.TenantID,
+		EnrollmentRequired: enrollmentRequired,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redis.Set(ctx, mfaChallengeKey(token), data, mfaChallengeTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallengeResponse{
+		MFARequired:        true,
+		MFAToken:           token,
+		EnrollmentRequired: enrollmentRequired,
+		ExpiresIn:          int64(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// BeginChallengeEnrollment starts enrollment for a user whose tenant
+// requires 2FA but who has not set it up yet
+func (s *MFAService) BeginChallengeEnrollment(ctx context.Context, token string) (*models.MFAEnrollResponse, error) {
+	challenge, err := s.loadChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.EnrollmentRequired {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return s.BeginEnrollment(tenantScope(ctx, challenge.TenantID), challenge.UserID)
+}
+
+// ChallengeUser returns the user a pending login belongs to
+func (s *MFAService) ChallengeUser(ctx context.Context, token string) (uuid.UUID, error) {
+	challenge, err := s.loadChallenge(ctx, token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return challenge.UserID, nil
+}
+
+// CompleteChallenge checks the second factor of a pending login and consumes
+// the challenge. For challenges that require enrollment the code confirms
+// the new secret and the fresh recovery codes are returned.
+func (s *MFAService) CompleteChallenge(ctx context.Context, token, code, recoveryCode string) (uuid.UUID, []string, error) {
+	challenge, err := s.loadChallenge(ctx, token)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	// The attempt is counted before the code is checked, so parallel
+	// guesses can't get past the limit
+	if err := s.reserveAttempt(ctx, token, challenge); err != nil {
+		return uuid.Nil, nil, err
+	}
+	ctx = tenantScope(ctx, challenge.TenantID)
+
+	var recoveryCodes []string
+	if challenge.EnrollmentRequired {
+		recoveryCodes, err = s.ConfirmEnrollment(ctx, challenge.UserID, code)
+		if err == ErrMFANotEnrolled {
+			return uuid.Nil, nil, ErrMFAEnrollmentPending
+		}
+	} else {
+		var mfa *models.UserMFA
+		mfa, err = s.enabledMFA(ctx, challenge.UserID)
+		if err == nil {
+			if recoveryCode != "" {
+				err = s.useRecoveryCode(ctx, mfa, recoveryCode)
+			} else {
+				err = s.verifyTOTP(ctx, mfa, code)
+			}
+		}
+	}
+
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	if err := s.redis.Del(ctx, mfaChallengeKey(token), mfaChallengeAttemptsPrefix+token).Err(); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return challenge.UserID, recoveryCodes, nil
+}
+
+func (s *MFAService) loadChallenge(ctx context.Context, token string) (*mfaChallenge, error) {
+	data, err := s.redis.Get(ctx, mfaChallengeKey(token)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &mfaChallenge{}
+	if err := json.Unmarshal(data, challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// reserveAttempt counts an attempt at the challenge and drops the
+// challenge once the limit is used up, forcing the user back to the
+// password step
+func (s *MFAService) reserveAttempt(ctx context.Context, token string, challenge *mfaChallenge) error {
+	attemptsKey := mfaChallengeAttemptsPrefix + token
+
+	pipe := s.redis.TxPipeline()
+	attempts := pipe.Incr(ctx, attemptsKey)
+	pipe.Expire(ctx, attemptsKey, mfaChallengeTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if attempts.Val() > mfaChallengeMaxAttempts {
+		return ErrInvalidMFAChallenge
+	}
+	if attempts.Val() == mfaChallengeMaxAttempts {
+		// This is the last attempt, later ones find no challenge
+		s.events.Publish(ctx, &SecurityEvent{
+			Type:     SecurityEventMFAAttemptsExceeded,
+			UserID:   challenge.UserID.String(),
+			TenantID: challenge.TenantID.String(),
+		})
+		return s.redis.Del(ctx, mfaChallengeKey(token)).Err()
+	}
+
+	return nil
+}
+
+func (s *MFAService) enabledMFA(ctx context.Context, userID uuid.UUID) (*models.UserMFA, error) {
+	mfa, err := s.mfaRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.IsEnabled {
+		return nil, ErrMFANotEnabled
+	}
+	return mfa, nil
+}
+
+func (s *MFAService) verifyCodeOrRecovery(ctx context.Context, mfa *models.UserMFA, code string) error {
+	if len(code) == totpDigits {
+		return s.verifyTOTP(ctx, mfa, code)
+	}
+	return s.useRecoveryCode(ctx, mfa, code)
+}
+
+// verifyTOTP validates the code and rejects a code that was already used
+// within its validity window
+func (s *MFAService) verifyTOTP(ctx context.Context, mfa *models.UserMFA, code string) error {
+	secret, err := s.decrypt(mfa.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := validateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	usedKey := totpUsedPrefix + mfa.UserID.String() + ":" + strconv.FormatInt(step, 10)
+	fresh, err := s.redis.SetNX(ctx, usedKey, 1, time.Duration(2*totpSkew+1)*totpPeriod).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// useRecoveryCode spends a recovery code. The code is removed in the
+// database, so it can't be spent twice by concurrent requests.
+func (s *MFAService) useRecoveryCode(ctx context.Context, mfa *models.UserMFA, code string) error {
+	hash := hashRecoveryCode(code)
+	if !containsString(mfa.RecoveryCodes, hash) {
+		return ErrInvalidMFACode
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, mfa.UserID, hash)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	remaining := len(mfa.RecoveryCodes) - 1
+
+	s.events.Publish(ctx, &SecurityEvent{
+		Type:     SecurityEventRecoveryCodeUsed,
+		UserID:   mfa.UserID.String(),
+		TenantID: mfa.TenantID.String(),
+		Details: map[string]string{
+			"remaining": strconv.Itoa(remaining),
+		},
+	})
+
+	return nil
+}
+
+func (s *MFAService) encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *MFAService) decrypt(ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", errors.New("invalid encrypted TOTP secret")
+	}
+
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// generateRecoveryCodes returns the codes to show to the user and the
+// hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		encoded := hex.EncodeToString(raw)
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+func mfaChallengeKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return mfaChallengePrefix + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,829 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	authorizationRequestPrefix = "oauth_authz_request:"
+	authorizationCodePrefix    = "oauth_code:"
+
+	authorizationRequestTTL = 10 * time.Minute
+	authorizationCodeTTL    = time.Minute
+	idTokenTTL              = time.Hour
+)
+
+// OAuth 2.0 grant types
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// SupportedScopes lists the OpenID Connect scopes clients may request
+var SupportedScopes = []string{"openid", "profile", "email", "phone"}
+
+// interactiveGrantTypes are the grants of clients acting for signed-in users
+var interactiveGrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+
+var ErrOAuthClientNotFound = errors.New("oauth client not found")
+
+// OAuthError is an RFC 6749 error response. Errors raised after the client
+// and redirect URI were validated are reported back to the client through
+// the redirect URI.
+type OAuthError struct {
+	Code        string
+	Description string
+
+	redirectURI string
+	state       string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// RedirectURL returns the client URL carrying the error, or "" when the
+// error must be shown to the user instead
+func (e *OAuthError) RedirectURL() string {
+	if e.redirectURI == "" {
+		return ""
+	}
+	params := url.Values{}
+	params.Set("error", e.Code)
+	params.Set("error_description", e.Description)
+	if e.state != "" {
+		params.Set("state", e.state)
+	}
+	return appendQuery(e.redirectURI, params)
+}
+
+// authorizationRequest is a validated /authorize request waiting for the
+// user to sign in on the hosted login page
+type authorizationRequest struct {
+	ClientID      string    `json:"client_id"`
+	TenantID      uuid.UUID `json:"tenant_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	State         string    `json:"state"`
+	Nonce         string    `json:"nonce"`
+	CodeChallenge string    `json:"code_challenge"`
+}
+
+// authorizationCode is the state behind an issued authorization code
+type authorizationCode struct {
+	ClientID      string    `json:"client_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	Nonce         string    `json:"nonce"`
+	CodeChallenge string    `json:"code_challenge"`
+	AuthTime      int64     `json:"auth_time"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. Type keeps
+// them from being accepted as access tokens, see VerifyAccessToken.
+type IDTokenClaims struct {
+	Type          string           `json:"type,omitempty"`
+	Nonce         string           `json:"nonce,omitempty"`
+	AuthTime      *jwt.NumericDate `json:"auth_time,omitempty"`
+	TenantID      string           `json:"tenant_id"`
+	Role          string           `json:"role,omitempty"`
+	Email         string           `json:"email,omitempty"`
+	EmailVerified *bool            `json:"email_verified,omitempty"`
+	PhoneNumber   string           `json:"phone_number,omitempty"`
+	Name          string           `json:"name,omitempty"`
+	GivenName     string           `json:"given_name,omitempty"`
+	FamilyName    string           `json:"family_name,omitempty"`
+
+	jwt.RegisteredClaims
+}
+
+// OIDCService implements the OAuth 2.0 authorization code flow with PKCE
+// and OpenID Connect on top of the regular login. Clients belong to a
+// tenant and only that tenant's users can sign in to them.
+//
+// Machine clients use the client_credentials grant instead. They belong to
+// a tenant or to the whole platform, their scopes are permissions and their
+// short-lived service tokens carry no user.
+type OIDCService struct {
+	clientRepo  repository.OAuthClientRepository
+	userRepo    repository.UserRepository
+	authService *AuthService
+	keys        *KeyManager
+	redis       *redis.Client
+	config      *config.Config
+}
+
+func NewOIDCService(
+	clientRepo repository.OAuthClientRepository,
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	keys *KeyManager,
+	redis *redis.Client,
+	config *config.Config,
+) *OIDCService {
+	return &OIDCService{
+		clientRepo:  clientRepo,
+		userRepo:    userRepo,
+		authService: authService,
+		keys:        keys,
+		redis:       redis,
+		config:      config,
+	}
+}
+
+// RegisterClient creates a client for the tenant, or a platform-wide
+// machine client when tenantID is uuid.Nil. The secret of confidential
+// clients is returned once and stored hashed.
+func (s *OIDCService) RegisterClient(ctx context.Context, tenantID uuid.UUID, req *models.CreateOAuthClientRequest) (*models.CreateOAuthClientResponse, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrOAuthClientNotFound
+	}
+
+	grantTypes := req.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = interactiveGrantTypes
+	}
+
+	var scopes []string
+	var err error
+	if containsString(grantTypes, GrantTypeClientCredentials) {
+		scopes, err = s.machineClientScopes(ctx, tenantID, grantTypes, req)
+	} else {
+		scopes, err = interactiveClientScopes(tenantID, grantTypes, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := generateRandomToken(18)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &models.OAuthClient{
+		TenantID:      tenantID,
+		ClientID:      clientID,
+		Name:          req.Name,
+		RedirectURIs:  req.RedirectURIs,
+		AllowedScopes: scopes,
+		GrantTypes:    grantTypes,
+		IsPublic:      req.IsPublic,
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+
+	var secret string
+	if !req.IsPublic {
+		secret, err = generateRandomToken(32)
+		if err != nil {
+			return nil, err
+		}
+		hash := hashClientSecret(secret)
+		client.ClientSecretHash = &hash
+	}
+
+	if err := s.clientRepo.Create(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateOAuthClientResponse{
+		Client:       client,
+		ClientSecret: secret,
+	}, nil
+}
+
+// interactiveClientScopes validates the registration of a client acting
+// for signed-in users and returns its OpenID Connect scopes
+func interactiveClientScopes(tenantID uuid.UUID, grantTypes []string, req *models.CreateOAuthClientRequest) ([]string, error) {
+	if tenantID == uuid.Nil {
+		return nil, &OAuthError{Code: "invalid_request", Description: "platform-wide clients must use the client_credentials grant"}
+	}
+	for _, grantType := range grantTypes {
+		if !containsString(interactiveGrantTypes, grantType) {
+			return nil, &OAuthError{Code: "invalid_request", Description: "unsupported grant type: " + grantType}
+		}
+	}
+
+	if len(req.RedirectURIs) == 0 {
+		return nil, &OAuthError{Code: "invalid_redirect_uri", Description: "at least one redirect URI is required"}
+	}
+	for _, redirectURI := range req.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, err
+		}
+	}
+
+	scopes := req.AllowedScopes
+	if len(scopes) == 0 {
+		scopes = SupportedScopes
+	}
+	for _, scope := range scopes {
+		if !containsString(SupportedScopes, scope) {
+			return nil, &OAuthError{Code: "invalid_scope", Description: "unsupported scope: " + scope}
+		}
+	}
+
+	return scopes, nil
+}
+
+// machineClientScopes validates the registration of a client_credentials
+// client and returns its scopes. Scopes are permissions, and callers can
+// only hand out permissions they hold themselves.
+func (s *OIDCService) machineClientScopes(ctx context.Context, tenantID uuid.UUID, grantTypes []string, req *models.CreateOAuthClientRequest) ([]string, error) {
+	if len(grantTypes) != 1 {
+		return nil, &OAuthError{Code: "invalid_request", Description: "client_credentials cannot be combined with other grant types"}
+	}
+	if req.IsPublic {
+		return nil, &OAuthError{Code: "invalid_request", Description: "machine clients must be confidential"}
+	}
+	if len(req.RedirectURIs) > 0 {
+		return nil, &OAuthError{Code: "invalid_request", Description: "machine clients have no redirect URIs"}
+	}
+	if len(req.AllowedScopes) == 0 {
+		return nil, &OAuthError{Code: "invalid_scope", Description: "machine clients need at least one scope"}
+	}
+
+	claims, _ := ClaimsFromContext(ctx)
+	granted := claims.EffectivePermissions()
+
+	for _, scope := range req.AllowedScopes {
+		valid := containsString(tenantPermissions, scope) ||
+			(tenantID == uuid.Nil && scope == PermissionTenantsManage)
+		if !valid {
+			return nil, &OAuthError{Code: "invalid_scope", Description: "unsupported scope: " + scope}
+		}
+		if !HasPermission(granted, scope) {
+			return nil, &OAuthError{Code: "invalid_scope", Description: "scope exceeds your own permissions: " + scope}
+		}
+	}
+
+	return req.AllowedScopes, nil
+}
+
+func (s *OIDCService) ListClients(ctx context.Context, tenantID uuid.UUID) ([]*models.OAuthClient, error) {
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrOAuthClientNotFound
+	}
+	return s.clientRepo.ListByTenant(ctx, tenantID)
+}
+
+func (s *OIDCService) DeleteClient(ctx context.Context, tenantID, id uuid.UUID) error {
+	if !canManageTenant(ctx, tenantID) {
+		return ErrOAuthClientNotFound
+	}
+
+	deleted, err := s.clientRepo.Delete(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrOAuthClientNotFound
+	}
+
+	return nil
+}
+
+// Authorize validates an authorization request and returns the hosted
+// login page URL the browser is sent to
+func (s *OIDCService) Authorize(ctx context.Context, req *models.AuthorizeRequest) (string, error) {
+	// Client IDs are unique across tenants, the client determines the tenant
+	client, err := s.clientRepo.GetByClientID(database.WithPlatformScope(ctx), req.ClientID)
+	if err != nil {
+		return "", err
+	}
+	if client == nil || !client.IsActive {
+		return "", &OAuthError{Code: "invalid_client", Description: "unknown client"}
+	}
+	if !containsString(client.GrantTypes, GrantTypeAuthorizationCode) {
+		return "", &OAuthError{Code: "unauthorized_client", Description: "client is not allowed to use the authorization code flow"}
+	}
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		return "", &OAuthError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	// From here on errors go back to the client
+	fail := func(code, description string) (string, error) {
+		return "", &OAuthError{Code: code, Description: description, redirectURI: req.RedirectURI, state: req.State}
+	}
+
+	if req.ResponseType != "code" {
+		return fail("unsupported_response_type", "only the authorization code flow is supported")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !containsString(scopes, "openid") {
+		return fail("invalid_scope", "the openid scope is required")
+	}
+	for _, scope := range scopes {
+		if !containsString(client.AllowedScopes, scope) {
+			return fail("invalid_scope", "scope not allowed for this client: "+scope)
+		}
+	}
+
+	// PKCE is mandatory for every client
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail("invalid_request", "code_challenge with method S256 is required")
+	}
+
+	requestID, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(&authorizationRequest{
+		ClientID:      client.ClientID,
+		TenantID:      client.TenantID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redis.Set(ctx, authorizationRequestPrefix+requestID, data, authorizationRequestTTL).Err(); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("request_id", requestID)
+	params.Set("tenant_id", client.TenantID.String())
+	params.Set("client_name", client.Name)
+	return appendQuery(s.config.OIDCLoginURL, params), nil
+}
+
+// ApproveAuthorization is called by the hosted login page once the user is
+// signed in. It issues the authorization code and returns the client
+// redirect URL.
+func (s *OIDCService) ApproveAuthorization(ctx context.Context, requestID string) (string, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return "", &OAuthError{Code: "access_denied", Description: "user is not signed in"}
+	}
+	// The code would be exchanged for a regular session of the user, free of
+	// the act claim and the impersonation TTL
+	if claims.IsImpersonated() {
+		return "", &OAuthError{Code: "access_denied", Description: ErrImpersonationForbidden.Error()}
+	}
+
+	data, err := s.redis.GetDel(ctx, authorizationRequestPrefix+requestID).Bytes()
+	if err == redis.Nil {
+		return "", &OAuthError{Code: "invalid_request", Description: "unknown or expired authorization request"}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	pending := &authorizationRequest{}
+	if err := json.Unmarshal(data, pending); err != nil {
+		return "", err
+	}
+
+	// Users may only sign in to clients of their own tenant
+	if claims.TenantID != pending.TenantID.String() {
+		return "", &OAuthError{
+			Code:        "access_denied",
+			Description: "user does not belong to the client's tenant",
+			redirectURI: pending.RedirectURI,
+			state:       pending.State,
+		}
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	authTime := time.Now().Unix()
+	if claims.IssuedAt != nil {
+		authTime = claims.IssuedAt.Unix()
+	}
+
+	code, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	data, err = json.Marshal(&authorizationCode{
+		ClientID:      pending.ClientID,
+		UserID:        userID,
+		RedirectURI:   pending.RedirectURI,
+		Scope:         pending.Scope,
+		Nonce:         pending.Nonce,
+		CodeChallenge: pending.CodeChallenge,
+		AuthTime:      authTime,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redis.Set(ctx, authorizationCodeKey(code), data, authorizationCodeTTL).Err(); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	if pending.State != "" {
+		params.Set("state", pending.State)
+	}
+	return appendQuery(pending.RedirectURI, params), nil
+}
+
+// Token implements the token endpoint. Client credentials come from HTTP
+// Basic auth or the form body.
+func (s *OIDCService) Token(ctx context.Context, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, req)
+	case GrantTypeRefreshToken:
+		return s.refresh(ctx, req)
+	case GrantTypeClientCredentials:
+		return s.clientCredentials(ctx, req)
+	default:
+		return nil, &OAuthError{Code: "unsupported_grant_type", Description: "unsupported grant_type: " + req.GrantType}
+	}
+}
+
+func (s *OIDCService) exchangeCode(ctx context.Context, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req, GrantTypeAuthorizationCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Codes are single use
+	data, err := s.redis.GetDel(ctx, authorizationCodeKey(req.Code)).Bytes()
+	if err == redis.Nil {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "invalid or expired authorization code"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	code := &authorizationCode{}
+	if err := json.Unmarshal(data, code); err != nil {
+		return nil, err
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "authorization code was issued to another client or redirect_uri"}
+	}
+	if !verifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "code_verifier does not match code_challenge"}
+	}
+
+	ctx = database.WithTenant(ctx, client.TenantID)
+	user, err := s.userRepo.GetByID(ctx, code.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive || user.TenantID != client.TenantID {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "user is no longer allowed to sign in"}
+	}
+
+	tokens, err := s.authService.IssueClientTokens(ctx, user, client.ClientID, code.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := s.signIDToken(user, client.ClientID, code.Nonce, code.AuthTime, strings.Fields(code.Scope))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      idToken,
+		Scope:        code.Scope,
+	}, nil
+}
+
+func (s *OIDCService) refresh(ctx context.Context, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req, GrantTypeRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.authService.ValidateToken(req.RefreshToken)
+	if err != nil || claims.TenantID != client.TenantID.String() || claims.ClientID != client.ClientID {
+		return nil, &OAuthError{Code: "invalid_grant", Description: "invalid refresh token"}
+	}
+
+	tokens, err := s.authService.RefreshClientTokens(ctx, req.RefreshToken, client.ClientID)
+	if err != nil {
+		return nil, &OAuthError{Code: "invalid_grant", Description: err.Error()}
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        claims.Scope,
+	}, nil
+}
+
+// clientCredentials issues a service token to a machine client. The token
+// is not refreshable: clients request a new one when it expires, which also
+// picks up a deactivated client or changed scopes.
+func (s *OIDCService) clientCredentials(ctx context.Context, req *models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req, GrantTypeClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+	if client.IsPublic {
+		return nil, &OAuthError{Code: "invalid_client", Description: "client authentication failed"}
+	}
+
+	// Without a scope parameter the client gets everything it is allowed
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.AllowedScopes
+	}
+	for _, scope := range scopes {
+		if !containsString(client.AllowedScopes, scope) {
+			return nil, &OAuthError{Code: "invalid_scope", Description: "scope not allowed for this client: " + scope}
+		}
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		TenantID:    tenantClaim(client.TenantID),
+		Type:        TokenTypeService,
+		ClientID:    client.ClientID,
+		Scope:       strings.Join(scopes, " "),
+		Permissions: scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   client.ClientID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.ServiceTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    s.config.Issuer,
+		},
+	}
+
+	accessToken, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.config.ServiceTokenTTL.Seconds()),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+// UserInfo returns the standard claims of the signed-in user. OAuth clients
+// only get the claims of the scopes they were granted.
+func (s *OIDCService) UserInfo(ctx context.Context) (*IDTokenClaims, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	scopes := SupportedScopes
+	if claims.IsClientBound() {
+		scopes = strings.Fields(claims.Scope)
+	}
+
+	info := userClaims(user, scopes)
+	info.Subject = user.ID.String()
+	return info, nil
+}
+
+// Introspect implements RFC 7662 token introspection for resource servers
+// that cannot verify tokens themselves. The caller authenticates as a
+// confidential client; clients of a tenant only see tokens of that tenant
+// as active. Refresh tokens and API keys are never reported active.
+func (s *OIDCService) Introspect(ctx context.Context, req *models.IntrospectionRequest) (*models.IntrospectionResponse, error) {
+	client, err := s.verifyClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if client.IsPublic {
+		return nil, &OAuthError{Code: "invalid_client", Description: "client authentication failed"}
+	}
+
+	inactive := &models.IntrospectionResponse{Active: false}
+
+	claims, err := s.authService.VerifyAccessToken(ctx, req.Token)
+	if err == ErrInvalidToken || err == ErrTokenRevoked {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.TenantID != uuid.Nil && claims.TenantID != client.TenantID.String() {
+		return inactive, nil
+	}
+
+	resp := &models.IntrospectionResponse{
+		Active:      true,
+		Scope:       claims.Scope,
+		ClientID:    claims.ClientID,
+		TokenType:   "Bearer",
+		Sub:         claims.Subject,
+		Iss:         claims.Issuer,
+		Jti:         claims.ID,
+		TenantID:    claims.TenantID,
+		Role:        claims.Role,
+		Permissions: claims.EffectivePermissions(),
+	}
+	if resp.Sub == "" {
+		resp.Sub = claims.UserID
+	}
+	if claims.ExpiresAt != nil {
+		resp.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.IsImpersonated() {
+		resp.Act = &models.TokenActor{
+			Subject:  claims.Act.Subject,
+			TenantID: claims.Act.TenantID,
+			Role:     claims.Act.Role,
+		}
+	}
+
+	return resp, nil
+}
+
+// authenticateClient checks the client credentials of the request and that
+// the client may use the grant type
+func (s *OIDCService) authenticateClient(ctx context.Context, req *models.OAuthTokenRequest, grantType string) (*models.OAuthClient, error) {
+	client, err := s.verifyClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsString(client.GrantTypes, grantType) {
+		return nil, &OAuthError{Code: "unauthorized_client", Description: "client is not allowed to use grant_type " + grantType}
+	}
+
+	return client, nil
+}
+
+// verifyClient looks up an active client and checks the secret of
+// confidential clients
+func (s *OIDCService) verifyClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	invalid := &OAuthError{Code: "invalid_client", Description: "client authentication failed"}
+
+	client, err := s.clientRepo.GetByClientID(database.WithPlatformScope(ctx), clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || !client.IsActive {
+		return nil, invalid
+	}
+
+	if !client.IsPublic {
+		if client.ClientSecretHash == nil || subtle.ConstantTimeCompare([]byte(hashClientSecret(clientSecret)), []byte(*client.ClientSecretHash)) != 1 {
+			return nil, invalid
+		}
+	}
+
+	return client, nil
+}
+
+func (s *OIDCService) signIDToken(user *models.User, clientID, nonce string, authTime int64, scopes []string) (string, error) {
+	claims := userClaims(user, scopes)
+	claims.Type = TokenTypeID
+	claims.Nonce = nonce
+	claims.AuthTime = jwt.NewNumericDate(time.Unix(authTime, 0))
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    s.config.Issuer,
+		Subject:   user.ID.String(),
+		Audience:  jwt.ClaimStrings{clientID},
+		ID:        uuid.New().String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(idTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	return s.keys.Sign(claims)
+}
+
+// userClaims maps the user to the standard claims released by the scopes
+func userClaims(user *models.User, scopes []string) *IDTokenClaims {
+	claims := &IDTokenClaims{
+		TenantID: user.TenantID.String(),
+		Role:     user.Role,
+	}
+
+	if containsString(scopes, "email") && user.Email != "" {
+		verified := user.IsVerified
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	if containsString(scopes, "phone") && user.Phone != nil {
+		claims.PhoneNumber = *user.Phone
+	}
+	if containsString(scopes, "profile") {
+		if user.FirstName != nil {
+			claims.GivenName = *user.FirstName
+		}
+		if user.LastName != nil {
+			claims.FamilyName = *user.LastName
+		}
+		claims.Name = strings.TrimSpace(claims.GivenName + " " + claims.FamilyName)
+	}
+
+	return claims
+}
+
+// validateRedirectURI accepts https URLs, http on localhost for development
+// and custom schemes used by mobile apps
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return &OAuthError{Code: "invalid_redirect_uri", Description: "invalid redirect URI: " + redirectURI}
+	}
+
+	if u.Scheme == "http" {
+		host := u.Hostname()
+		if host != "localhost" && host != "127.0.0.1" {
+			return &OAuthError{Code: "invalid_redirect_uri", Description: "redirect URI must use https: " + redirectURI}
+		}
+	}
+
+	return nil
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	if verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func hashClientSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func authorizationCodeKey(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return authorizationCodePrefix + hex.EncodeToString(hash[:])
+}
+
+func appendQuery(baseURL string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(baseURL, "?") {
+		separator = "&"
+	}
+	return baseURL + separator + params.Encode()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
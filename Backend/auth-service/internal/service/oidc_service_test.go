@@ -0,0 +1,31 @@
+package service
+
+import "testing"
+
+// S256 example of RFC 7636 appendix B
+const (
+	pkceTestVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	pkceTestChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"rfc7636 example", pkceTestVerifier, pkceTestChallenge, true},
+		{"wrong verifier", pkceTestVerifier[1:], pkceTestChallenge, false},
+		{"plain method", pkceTestVerifier, pkceTestVerifier, false},
+		{"padded challenge", pkceTestVerifier, pkceTestChallenge + "=", false},
+		{"empty verifier", "", pkceTestChallenge, false},
+		{"empty challenge", pkceTestVerifier, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := verifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+			t.Errorf("%s: verifyPKCE = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"auth-service/internal/models"
+	"auth-service/internal/passhash"
+	"auth-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// minPasswordLength applies to every tenant, policies can only raise it
+	minPasswordLength = 8
+	// breachedPrefixMinLength rejects prefixes so short that they would
+	// match a large share of all passwords
+	breachedPrefixMinLength = 5
+)
+
+var (
+	ErrPasswordTooShort  = errors.New("password is shorter than the minimum length")
+	ErrPasswordTooSimple = errors.New("password must mix the character classes required by the password policy")
+	ErrPasswordReused    = errors.New("password was used recently, choose a different one")
+	ErrPasswordBreached  = errors.New("password appears in a list of breached passwords, choose a different one")
+	ErrPasswordExpired   = errors.New("password has expired and must be reset")
+)
+
+// IsPasswordPolicyError reports whether err rejects a new password, as
+// opposed to failing to set it
+func IsPasswordPolicyError(err error) bool {
+	switch err {
+	case ErrPasswordTooShort, ErrPasswordTooSimple, ErrPasswordReused, ErrPasswordBreached:
+		return true
+	}
+	return false
+}
+
+// BreachedPasswords screens passwords against an offline list of SHA-1
+// hash prefixes of breached passwords, such as an export of the Have I Been
+// Pwned corpus. Nothing is looked up over the network.
+type BreachedPasswords struct {
+	prefixes map[string]struct{}
+	lengths  []int
+}
+
+// LoadBreachedPasswords reads a list with one upper- or lowercase hex SHA-1
+// prefix per line, at least breachedPrefixMinLength digits long. A ":count"
+// suffix, blank lines and lines starting with # are ignored. An empty path
+// returns an empty list.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	b := &BreachedPasswords{prefixes: map[string]struct{}{}}
+	if path == "" {
+		return b, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lengths := map[int]bool{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		prefix, _, _ := strings.Cut(line, ":")
+		prefix = strings.ToUpper(strings.TrimSpace(prefix))
+		if strings.Trim(prefix, "0123456789ABCDEF") != "" ||
+			len(prefix) < breachedPrefixMinLength || len(prefix) > sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: expected a SHA-1 prefix of %d to %d hex digits", path, lineNumber, breachedPrefixMinLength, sha1.Size*2)
+		}
+
+		b.prefixes[prefix] = struct{}{}
+		lengths[len(prefix)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for length := range lengths {
+		b.lengths = append(b.lengths, length)
+	}
+	sort.Ints(b.lengths)
+
+	return b, nil
+}
+
+// Len returns the number of prefixes in the list
+func (b *BreachedPasswords) Len() int {
+	return len(b.prefixes)
+}
+
+// Contains reports whether the SHA-1 hash of the password starts with one
+// of the listed prefixes
+func (b *BreachedPasswords) Contains(password string) bool {
+	if len(b.prefixes) == 0 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	for _, length := range b.lengths {
+		if _, ok := b.prefixes[digest[:length]]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PasswordPolicyService enforces the password policy of the tenant, see
+// models.TenantPasswordPolicy, wherever a password is set, and keeps the
+// password history that reuse checks and password age rely on. Breached
+// passwords are rejected for every tenant. Passwords are hashed with the
+// configured passhash.Hasher.
+type PasswordPolicyService struct {
+	userRepo    repository.UserRepository
+	historyRepo repository.PasswordHistoryRepository
+	tenantRepo  repository.TenantRepository
+	hasher      passhash.Hasher
+	breached    *BreachedPasswords
+}
+
+func NewPasswordPolicyService(
+	userRepo repository.UserRepository,
+	historyRepo repository.PasswordHistoryRepository,
+	tenantRepo repository.TenantRepository,
+	hasher passhash.Hasher,
+	breached *BreachedPasswords,
+) *PasswordPolicyService {
+	return &PasswordPolicyService{
+		userRepo:    userRepo,
+		historyRepo: historyRepo,
+		tenantRepo:  tenantRepo,
+		hasher:      hasher,
+		breached:    breached,
+	}
+}
+
+// Policy returns the password policy of the tenant. Platform identities and
+// unknown tenants get the defaults.
+func (s *PasswordPolicyService) Policy(ctx context.Context, tenantID uuid.UUID) (*models.TenantPasswordPolicy, error) {
+	policy := &models.TenantPasswordPolicy{}
+	if tenantID == uuid.Nil {
+		return policy, nil
+	}
+
+	tenant, err := s.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil || tenant == nil {
+		return policy, err
+	}
+
+	tenantConfig, err := tenant.ParseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tenantConfig.PasswordPolicy, nil
+}
+
+// Check validates a new password of a user of the tenant. user is nil for
+// accounts that don't exist yet, which have no history to check.
+func (s *PasswordPolicyService) Check(ctx context.Context, tenantID uuid.UUID, user *models.User, password string) error {
+	policy, err := s.Policy(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	minLength := policy.MinLength
+	if minLength < minPasswordLength {
+		minLength = minPasswordLength
+	}
+	if utf8.RuneCountInString(password) < minLength {
+		return ErrPasswordTooShort
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+	if (policy.RequireUppercase && !hasUpper) || (policy.RequireLowercase && !hasLower) ||
+		(policy.RequireDigit && !hasDigit) || (policy.RequireSymbol && !hasSymbol) {
+		return ErrPasswordTooSimple
+	}
+
+	if s.breached.Contains(password) {
+		return ErrPasswordBreached
+	}
+
+	if user != nil && policy.HistoryDepth > 0 {
+		hashes, err := s.historyRepo.ListRecent(ctx, user.ID, policy.HistoryDepth)
+		if err != nil {
+			return err
+		}
+		// Accounts from before the history was kept only have their
+		// current password
+		if user.PasswordHash != "" && !containsString(hashes, user.PasswordHash) {
+			hashes = append(hashes, user.PasswordHash)
+		}
+
+		for _, hash := range hashes {
+			if ok, _ := passhash.Verify(hash, password); ok {
+				return ErrPasswordReused
+			}
+		}
+	}
+
+	return nil
+}
+
+// Hash checks a new password against the policy and returns its hash
+func (s *PasswordPolicyService) Hash(ctx context.Context, tenantID uuid.UUID, user *models.User, password string) (string, error) {
+	if err := s.Check(ctx, tenantID, user, password); err != nil {
+		return "", err
+	}
+
+	return s.hasher.Hash(password)
+}
+
+// Verify checks a password against the user's hash, whatever scheme it was
+// made with
+func (s *PasswordPolicyService) Verify(user *models.User, password string) bool {
+	ok, err := passhash.Verify(user.PasswordHash, password)
+	if err != nil && err != passhash.ErrUnsupportedHash {
+		log.Printf("Failed to verify password of user %s: %v", user.ID, err)
+	}
+	return ok
+}
+
+// Upgrade rehashes a verified password whose hash was made with a legacy
+// scheme or parameters. The password itself is unchanged, so neither the
+// policy nor the history apply.
+func (s *PasswordPolicyService) Upgrade(ctx context.Context, user *models.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of user %s: %v", user.ID, err)
+		return
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		log.Printf("Failed to store rehashed password of user %s: %v", user.ID, err)
+		return
+	}
+	user.PasswordHash = hashedPassword
+}
+
+// SetPassword replaces the password of an existing user and records it in
+// the history
+func (s *PasswordPolicyService) SetPassword(ctx context.Context, user *models.User, password string) error {
+	hashedPassword, err := s.Hash(ctx, user.TenantID, user, password)
+	if err != nil {
+		return err
+	}
+
+	return s.StorePassword(ctx, user, hashedPassword)
+}
+
+// StorePassword replaces the password of an existing user with a hash
+// returned by Hash and records it in the history
+func (s *PasswordPolicyService) StorePassword(ctx context.Context, user *models.User, passwordHash string) error {
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	user.PasswordHash = passwordHash
+
+	// The password is set, a missing history entry only weakens reuse checks
+	if err := s.Record(ctx, user); err != nil {
+		log.Printf("Failed to record password of user %s: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// Record adds the user's current password hash to the history, keeping as
+// many entries as the reuse check of the policy needs
+func (s *PasswordPolicyService) Record(ctx context.Context, user *models.User) error {
+	policy, err := s.Policy(ctx, user.TenantID)
+	if err != nil {
+		return err
+	}
+
+	// The newest entry dates the current password for the max age check
+	keep := policy.HistoryDepth
+	if keep < 1 {
+		keep = 1
+	}
+
+	if err := s.historyRepo.Add(ctx, user.ID, user.TenantID, user.PasswordHash, keep); err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+
+	return nil
+}
+
+// Expired reports whether the user's password is older than the max age of
+// the policy. Passwords set before the history was kept date from the
+// creation of the account.
+func (s *PasswordPolicyService) Expired(ctx context.Context, user *models.User, policy *models.TenantPasswordPolicy) (bool, error) {
+	if policy.MaxAgeDays <= 0 {
+		return false, nil
+	}
+
+	changedAt, err := s.historyRepo.LastChangedAt(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if changedAt == nil {
+		changedAt = &user.CreatedAt
+	}
+
+	maxAge := time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+	return time.Since(*changedAt) > maxAge, nil
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/mail"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	passwordResetPrefix     = "password_reset:"
+	passwordResetUserPrefix = "password_reset_user:"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService issues and consumes password reset tokens. Only a
+// SHA-256 hash of each token is stored, namespaced by tenant, and every
+// token can be used once.
+type PasswordResetService struct {
+	userRepo    repository.UserRepository
+	authService *AuthService
+	passwords   *PasswordPolicyService
+	audit       *AuditService
+	redis       *redis.Client
+	mailer      mail.Sender
+	config      *config.Config
+}
+
+func NewPasswordResetService(
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	passwords *PasswordPolicyService,
+	audit *AuditService,
+	redis *redis.Client,
+	mailer mail.Sender,
+	config *config.Config,
+) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo:    userRepo,
+		authService: authService,
+		passwords:   passwords,
+		audit:       audit,
+		redis:       redis,
+		mailer:      mailer,
+		config:      config,
+	}
+}
+
+// RequestReset emails a reset link if an active account exists. It returns
+// nil for unknown emails so callers cannot probe for registered addresses.
+func (s *PasswordResetService) RequestReset(ctx context.Context, tenantID uuid.UUID, email string) error {
+	ctx = tenantScope(ctx, tenantID)
+
+	user, err := s.userRepo.GetByEmail(ctx, tenantID, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || !user.IsActive {
+		return nil
+	}
+
+	token, err := s.issueToken(ctx, user, s.config.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	msg := &mail.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Someone requested a password reset for your account.\n\n"+
+				"Follow the link below to choose a new password:\n%s\n\n"+
+				"The link expires in %s. If you did not request a reset, ignore this email.\n",
+			linkWithToken(s.config.PasswordResetURL, token), s.config.PasswordResetTTL,
+		),
+	}
+
+	// Send asynchronously so response time does not depend on whether the
+	// account exists
+	go func() {
+		if err := s.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// SendInvitation emails a link to choose a password to a user created
+// without one, such as by a bulk import. The link is a reset token that
+// stays valid for PasswordSetupTTL.
+func (s *PasswordResetService) SendInvitation(ctx context.Context, user *models.User) error {
+	token, err := s.issueToken(ctx, user, s.config.PasswordSetupTTL)
+	if err != nil {
+		return err
+	}
+
+	msg := &mail.Message{
+		To:      user.Email,
+		Subject: "Your account is ready",
+		Body: fmt.Sprintf(
+			"An account has been created for you.\n\n"+
+				"Follow the link below to choose your password:\n%s\n\n"+
+				"The link expires in %s.\n",
+			linkWithToken(s.config.PasswordResetURL, token), s.config.PasswordSetupTTL,
+		),
+	}
+
+	return s.mailer.Send(ctx, msg)
+}
+
+// issueToken stores a new reset token of the user. Only the latest token of
+// a user stays valid.
+func (s *PasswordResetService) issueToken(ctx context.Context, user *models.User, ttl time.Duration) (string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	tokenKey := passwordResetKey(user.TenantID, token)
+	userKey := passwordResetUserPrefix + user.TenantID.String() + ":" + user.ID.String()
+
+	previous, err := s.redis.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	pipe := s.redis.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, previous)
+	}
+	pipe.Set(ctx, tokenKey, user.ID.String(), ttl)
+	pipe.Set(ctx, userKey, tokenKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ResetPassword consumes the token, sets the new password and revokes all
+// existing sessions of the user. A password rejected by the tenant's
+// password policy leaves the token valid for another attempt.
+func (s *PasswordResetService) ResetPassword(ctx context.Context, tenantID uuid.UUID, token, newPassword string) error {
+	tokenKey := passwordResetKey(tenantID, token)
+
+	userIDStr, err := s.redis.Get(ctx, tokenKey).Result()
+	if err == redis.Nil {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get reset token: %w", err)
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	ctx = tenantScope(ctx, tenantID)
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.TenantID != tenantID || !user.IsActive {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.passwords.Hash(ctx, tenantID, user, newPassword)
+	if err != nil {
+		return err
+	}
+
+	// Only one of concurrent requests with the token gets to use it
+	if err := s.redis.GetDel(ctx, tokenKey).Err(); err == redis.Nil {
+		return ErrInvalidResetToken
+	} else if err != nil {
+		return fmt.Errorf("failed to consume reset token: %w", err)
+	}
+	s.redis.Del(ctx, passwordResetUserPrefix+tenantID.String()+":"+userIDStr)
+
+	if err := s.passwords.StorePassword(ctx, user, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := s.authService.LogoutAll(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(user.TenantID),
+		ActorID:      &user.ID,
+		Action:       AuditActionPasswordReset,
+		ResourceType: AuditResourceUser,
+		ResourceID:   &user.ID,
+	})
+
+	return nil
+}
+
+// linkWithToken appends the token as a query parameter to a frontend URL
+func linkWithToken(baseURL, token string) string {
+	separator := "?"
+	if strings.Contains(baseURL, "?") {
+		separator = "&"
+	}
+	return baseURL + separator + "token=" + url.QueryEscape(token)
+}
+
+func passwordResetKey(tenantID uuid.UUID, token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return passwordResetPrefix + tenantID.String() + ":" + hex.EncodeToString(hash[:])
+}
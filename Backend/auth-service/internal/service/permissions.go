@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrPermissionNotHeld is returned when a caller tries to grant, through a
+// role, an invitation or an import, permissions it does not hold itself
+var ErrPermissionNotHeld = errors.New("cannot grant permissions the caller does not hold")
+
+// Permissions are named "<resource>:<action>". Access tokens carry the
+// caller's effective permissions in the perms claim, so services can check
+// them without calling back to auth-service.
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionUsersDelete = "users:delete"
+	PermissionUsersUnlock = "users:unlock"
+
+	PermissionUsersImpersonate = "users:impersonate"
+
+	PermissionSessionsManage = "sessions:manage"
+
+	PermissionAuditRead = "audit:read"
+
+	PermissionRolesManage        = "roles:manage"
+	PermissionOAuthClientsManage = "oauth_clients:manage"
+	PermissionAPIKeysManage      = "api_keys:manage"
+
+	PermissionShipmentsRead   = "shipments:read"
+	PermissionShipmentsCreate = "shipments:create"
+	PermissionShipmentsCancel = "shipments:cancel"
+	PermissionShipmentsLabel  = "shipments:label"
+
+	PermissionPVZRead = "pvz:read"
+	PermissionPVZSync = "pvz:sync"
+
+	// Platform permissions are never granted by tenants
+	PermissionTenantsManage = "tenants:manage"
+
+	// PermissionAll matches every permission
+	PermissionAll = "*"
+)
+
+// tenantPermissions can be granted by tenant admins through custom roles
+var tenantPermissions = []string{
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersDelete,
+	PermissionUsersUnlock,
+	PermissionUsersImpersonate,
+	PermissionSessionsManage,
+	PermissionAuditRead,
+	PermissionRolesManage,
+	PermissionOAuthClientsManage,
+	PermissionAPIKeysManage,
+	PermissionShipmentsRead,
+	PermissionShipmentsCreate,
+	PermissionShipmentsCancel,
+	PermissionShipmentsLabel,
+	PermissionPVZRead,
+	PermissionPVZSync,
+}
+
+// builtinRoles are the roles of the users table CHECK constraint
+var builtinRoles = map[string][]string{
+	"super_admin":  {PermissionAll},
+	"tenant_admin": tenantPermissions,
+	"vendor": {
+		PermissionShipmentsRead,
+		PermissionShipmentsCreate,
+		PermissionShipmentsLabel,
+		PermissionPVZRead,
+	},
+	"operator": {
+		PermissionShipmentsRead,
+		PermissionPVZRead,
+	},
+	"customer": {
+		PermissionPVZRead,
+	},
+}
+
+// TenantPermissions lists the permissions custom roles may contain
+func TenantPermissions() []string {
+	return append([]string(nil), tenantPermissions...)
+}
+
+// RolePermissions returns the permissions of a built-in role
+func RolePermissions(role string) []string {
+	return append([]string(nil), builtinRoles[role]...)
+}
+
+// IsBuiltinRole reports whether the name is reserved for a built-in role
+func IsBuiltinRole(role string) bool {
+	_, ok := builtinRoles[role]
+	return ok
+}
+
+// HasPermission reports whether the granted set satisfies the required
+// permission. "*" grants everything and "<resource>:*" every action on the
+// resource.
+func HasPermission(granted []string, required string) bool {
+	resource := required
+	if i := strings.Index(required, ":"); i >= 0 {
+		resource = required[:i]
+	}
+
+	for _, permission := range granted {
+		if permission == PermissionAll || permission == required || permission == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callerHolds reports whether the caller of ctx holds every permission, by
+// its custom role or the defaults of its built-in role
+func callerHolds(ctx context.Context, permissions []string) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	granted := claims.EffectivePermissions()
+	for _, permission := range permissions {
+		if !HasPermission(granted, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergePermissions returns the sorted union of the permission sets
+func mergePermissions(sets ...[]string) []string {
+	seen := map[string]bool{}
+	merged := []string{}
+	for _, set := range sets {
+		for _, permission := range set {
+			if !seen[permission] {
+				seen[permission] = true
+				merged = append(merged, permission)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
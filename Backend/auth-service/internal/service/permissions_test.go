@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var testTenantID = uuid.MustParse("6f1c2b8e-7d3a-4c55-9e0f-2a6b4c8d1e3f")
+
+// staffManager may manage roles but holds fewer permissions than a tenant
+// admin, it must not be able to grant itself or anyone else more
+func staffManagerContext() context.Context {
+	return ContextWithClaims(context.Background(), &Claims{
+		UserID:   uuid.NewString(),
+		TenantID: testTenantID.String(),
+		Role:     "operator",
+		Type:     TokenTypeAccess,
+		Permissions: []string{
+			PermissionRolesManage,
+			PermissionUsersRead,
+			PermissionUsersWrite,
+			PermissionShipmentsRead,
+			PermissionPVZRead,
+		},
+	})
+}
+
+// fakeRoleRepository serves a single custom role, other methods are unused
+type fakeRoleRepository struct {
+	repository.RoleRepository
+	role *models.Role
+}
+
+func (r *fakeRoleRepository) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*models.Role, error) {
+	if r.role != nil && r.role.TenantID == tenantID && r.role.ID == id {
+		return r.role, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeRoleRepository) GetForUser(ctx context.Context, userID uuid.UUID) (*models.Role, error) {
+	return nil, nil
+}
+
+// fakeUserRepository serves a single user, other methods are unused
+type fakeUserRepository struct {
+	repository.UserRepository
+	user *models.User
+}
+
+func (r *fakeUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	if r.user != nil && r.user.ID == id {
+		return r.user, nil
+	}
+	return nil, nil
+}
+
+func TestCallerHolds(t *testing.T) {
+	tests := []struct {
+		name        string
+		claims      *Claims
+		permissions []string
+		want        bool
+	}{
+		{"role defaults", &Claims{Role: "tenant_admin"}, []string{PermissionUsersDelete}, true},
+		{"super admin", &Claims{Role: "super_admin"}, []string{PermissionTenantsManage}, true},
+		{"resource wildcard", &Claims{Role: "operator", Permissions: []string{"shipments:*"}}, []string{PermissionShipmentsCancel}, true},
+		{"custom role", &Claims{Role: "operator", Permissions: []string{PermissionRolesManage}}, []string{PermissionUsersDelete}, false},
+		{"one of several", &Claims{Role: "vendor"}, []string{PermissionShipmentsRead, PermissionShipmentsCancel}, false},
+		{"nothing to grant", &Claims{Role: "customer"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		ctx := ContextWithClaims(context.Background(), tt.claims)
+		if got := callerHolds(ctx, tt.permissions); got != tt.want {
+			t.Errorf("%s: callerHolds = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if callerHolds(context.Background(), nil) {
+		t.Error("callerHolds without claims = true")
+	}
+}
+
+func TestCreateRoleRefusesUnheldPermissions(t *testing.T) {
+	s := &RoleService{}
+
+	_, err := s.CreateRole(staffManagerContext(), testTenantID, &models.RoleRequest{
+		Name:        "escalated",
+		Permissions: []string{PermissionUsersRead, PermissionUsersDelete},
+	})
+	if err != ErrPermissionNotHeld {
+		t.Fatalf("CreateRole = %v, want ErrPermissionNotHeld", err)
+	}
+}
+
+func TestUpdateRoleRefusesUnheldPermissions(t *testing.T) {
+	role := &models.Role{ID: uuid.New(), TenantID: testTenantID, Name: "support", Permissions: []string{PermissionUsersRead}}
+	s := &RoleService{roleRepo: &fakeRoleRepository{role: role}}
+
+	_, err := s.UpdateRole(staffManagerContext(), testTenantID, role.ID, &models.RoleRequest{
+		Name:        "support",
+		Permissions: []string{PermissionUsersRead, PermissionAPIKeysManage},
+	})
+	if err != ErrPermissionNotHeld {
+		t.Fatalf("UpdateRole = %v, want ErrPermissionNotHeld", err)
+	}
+}
+
+func TestAssignRoleRefusesUnheldPermissions(t *testing.T) {
+	role := &models.Role{ID: uuid.New(), TenantID: testTenantID, Name: "admins", Permissions: TenantPermissions()}
+	user := &models.User{ID: uuid.New(), TenantID: testTenantID, Role: "operator"}
+	s := &RoleService{
+		roleRepo: &fakeRoleRepository{role: role},
+		userRepo: &fakeUserRepository{user: user},
+	}
+
+	if err := s.AssignRole(staffManagerContext(), user.ID, &role.ID); err != ErrPermissionNotHeld {
+		t.Fatalf("AssignRole = %v, want ErrPermissionNotHeld", err)
+	}
+}
+
+func TestUpdateUserRefusesUnheldRole(t *testing.T) {
+	user := &models.User{ID: uuid.New(), TenantID: testTenantID, Role: "operator"}
+	s := &UserService{userRepo: &fakeUserRepository{user: user}}
+
+	role := "tenant_admin"
+	_, err := s.UpdateUser(staffManagerContext(), user.ID, &models.AdminUpdateUserRequest{Role: &role})
+	if err != ErrPermissionNotHeld {
+		t.Fatalf("UpdateUser = %v, want ErrPermissionNotHeld", err)
+	}
+}
+
+func TestCreateInvitationRefusesUnheldRole(t *testing.T) {
+	s := &InvitationService{}
+
+	email := "new.admin@example.com"
+	_, err := s.Create(staffManagerContext(), testTenantID, &models.CreateInvitationRequest{
+		Email: &email,
+		Role:  "tenant_admin",
+	})
+	if err != ErrPermissionNotHeld {
+		t.Fatalf("Create = %v, want ErrPermissionNotHeld", err)
+	}
+}
+
+func TestCreateImportJobRefusesUnheldRole(t *testing.T) {
+	s := &UserImportService{}
+
+	data := []byte(`{"email":"clerk@example.com","role":"operator"}
+{"email":"new.admin@example.com","role":"tenant_admin"}
+`)
+	_, err := s.CreateJob(staffManagerContext(), testTenantID, UserImportFormatJSONL, false, data)
+	if err != ErrPermissionNotHeld {
+		t.Fatalf("CreateJob = %v, want ErrPermissionNotHeld", err)
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+	"auth-service/internal/sms"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	phoneOTPPrefix         = "phone_otp:"
+	phoneOTPCooldownPrefix = "phone_otp_cooldown:"
+	phoneOTPSendsPrefix    = "phone_otp_sends:"
+
+	phoneOTPDigits      = 6
+	phoneOTPMaxAttempts = 5
+
+	// Caps on SMS volume, against SMS pumping fraud
+	phoneOTPSendWindow   = time.Hour
+	phoneOTPPhoneSendCap = 5
+	phoneOTPIPSendCap    = 20
+)
+
+var (
+	ErrInvalidPhone = errors.New("invalid phone number")
+	ErrInvalidOTP   = errors.New("invalid or expired code")
+)
+
+// OTPRateLimitError is returned when a code was requested too recently or
+// too often
+type OTPRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OTPRateLimitError) Error() string {
+	return fmt.Sprintf("too many code requests, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// checkPhoneOTPScript compares the code and counts failed attempts. The code
+// is dropped after a match or when the attempt limit is reached.
+var checkPhoneOTPScript = redis.NewScript(`
+local code = redis.call("HGET", KEYS[1], "code")
+if not code then
+	return -1
+end
+if code == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+local attempts = redis.call("HINCRBY", KEYS[1], "attempts", 1)
+if attempts >= tonumber(ARGV[2]) then
+	redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// PhoneOTPService implements passwordless login with one-time codes sent
+// by SMS. Accounts are created on the first verified login.
+type PhoneOTPService struct {
+	userRepo    repository.UserRepository
+	authService *AuthService
+	sender      sms.Sender
+	redis       *redis.Client
+	config      *config.Config
+}
+
+func NewPhoneOTPService(
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	sender sms.Sender,
+	redis *redis.Client,
+	config *config.Config,
+) *PhoneOTPService {
+	return &PhoneOTPService{
+		userRepo:    userRepo,
+		authService: authService,
+		sender:      sender,
+		redis:       redis,
+		config:      config,
+	}
+}
+
+// RequestCode sends a new login code to the phone, replacing any previous one
+func (s *PhoneOTPService) RequestCode(ctx context.Context, tenantID uuid.UUID, phone string) error {
+	phone, err := normalizePhone(phone)
+	if err != nil {
+		return err
+	}
+
+	subject := tenantID.String() + ":" + phone
+
+	ok, err := s.redis.SetNX(ctx, phoneOTPCooldownPrefix+subject, 1, s.config.PhoneOTPCooldown).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ttl, err := s.redis.TTL(ctx, phoneOTPCooldownPrefix+subject).Result()
+		if err != nil {
+			return err
+		}
+		return &OTPRateLimitError{RetryAfter: ttl}
+	}
+
+	if err := s.checkSendCap(ctx, "phone:"+subject, phoneOTPPhoneSendCap); err != nil {
+		return err
+	}
+	if ip := ClientInfoFromContext(ctx).IP; ip != "" {
+		if err := s.checkSendCap(ctx, "ip:"+ip, phoneOTPIPSendCap); err != nil {
+			return err
+		}
+	}
+
+	code, err := generateNumericCode(phoneOTPDigits)
+	if err != nil {
+		return err
+	}
+
+	key := phoneOTPPrefix + subject
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "code", hashPhoneOTP(subject, code), "attempts", 0)
+	pipe.Expire(ctx, key, s.config.PhoneOTPTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	return s.sender.Send(ctx, &sms.Message{
+		To:   phone,
+		Body: fmt.Sprintf("Your login code: %s. It expires in %d minutes.", code, int(s.config.PhoneOTPTTL.Minutes())),
+	})
+}
+
+// Verify checks the code and logs the user in, creating a customer account
+// for phones that are not registered in the tenant yet
+func (s *PhoneOTPService) Verify(ctx context.Context, tenantID uuid.UUID, phone, code string) (*models.LoginResponse, error) {
+	phone, err := normalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := tenantID.String() + ":" + phone
+
+	result, err := checkPhoneOTPScript.Run(ctx, s.redis,
+		[]string{phoneOTPPrefix + subject},
+		hashPhoneOTP(subject, strings.TrimSpace(code)),
+		phoneOTPMaxAttempts,
+	).Int()
+	if err != nil {
+		return nil, err
+	}
+	if result != 1 {
+		return nil, ErrInvalidOTP
+	}
+
+	ctx = database.WithTenant(ctx, tenantID)
+	user, err := s.userRepo.FindByPhone(ctx, tenantID, phone)
+	if err != nil {
+		return nil, err
+	}
+
+	newUser := user == nil
+	if newUser {
+		user = &models.User{
+			TenantID: tenantID,
+			Phone:    &phone,
+			Role:     "customer",
+			IsActive: true,
+		}
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("account is deactivated")
+	}
+
+	tenantConfig, err := s.authService.tenantConfig(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.authService.completeLogin(ctx, user, tenantConfig)
+	if err != nil {
+		return nil, err
+	}
+	resp.NewUser = newUser
+
+	return resp, nil
+}
+
+// checkSendCap counts sends per subject within a fixed window
+func (s *PhoneOTPService) checkSendCap(ctx context.Context, subject string, limit int64) error {
+	key := phoneOTPSendsPrefix + subject
+
+	count, err := s.redis.Incr(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		s.redis.Expire(ctx, key, phoneOTPSendWindow)
+	}
+
+	if count > limit {
+		ttl, err := s.redis.TTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		return &OTPRateLimitError{RetryAfter: ttl}
+	}
+
+	return nil
+}
+
+// normalizePhone converts a phone number to E.164. Russian numbers written
+// with the trunk prefix 8 are converted to +7.
+func normalizePhone(phone string) (string, error) {
+	var digits strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	number := digits.String()
+	if len(number) == 11 && strings.HasPrefix(number, "8") && !strings.HasPrefix(strings.TrimSpace(phone), "+") {
+		number = "7" + number[1:]
+	}
+
+	if len(number) < 10 || len(number) > 15 {
+		return "", ErrInvalidPhone
+	}
+
+	return "+" + number, nil
+}
+
+func generateNumericCode(digits int) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
+
+func hashPhoneOTP(subject, code string) string {
+	hash := sha256.Sum256([]byte(subject + ":" + code))
+	return hex.EncodeToString(hash[:])
+}
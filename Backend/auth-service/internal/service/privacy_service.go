@@ -0,0 +1,338 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Users anonymized per run of the erasure job
+const erasureBatchSize = 100
+
+var (
+	ErrErasurePending    = errors.New("erasure is already scheduled")
+	ErrNoErasurePending  = errors.New("no erasure is scheduled")
+	ErrAccountAnonymized = errors.New("account has been anonymized")
+)
+
+// PrivacyService handles data subject requests: exports of everything
+// stored about a user and erasure of their personal data. Erasure happens
+// after a grace period during which the request can be cancelled.
+type PrivacyService struct {
+	userRepo    repository.UserRepository
+	privacyRepo repository.PrivacyRepository
+	auditRepo   repository.AuditRepository
+	audit       *AuditService
+	families    *RefreshFamilyStore
+	denylist    *TokenDenylist
+	gracePeriod time.Duration
+	interval    time.Duration
+}
+
+func NewPrivacyService(
+	userRepo repository.UserRepository,
+	privacyRepo repository.PrivacyRepository,
+	auditRepo repository.AuditRepository,
+	audit *AuditService,
+	redis *redis.Client,
+	cfg *config.Config,
+) *PrivacyService {
+	return &PrivacyService{
+		userRepo:    userRepo,
+		privacyRepo: privacyRepo,
+		auditRepo:   auditRepo,
+		audit:       audit,
+		families:    NewRefreshFamilyStore(redis),
+		denylist:    NewTokenDenylist(redis),
+		gracePeriod: cfg.DataErasureGracePeriod,
+		interval:    cfg.DataErasureInterval,
+	}
+}
+
+// Start anonymizes users whose grace period has ended, periodically until
+// ctx is cancelled
+func (s *PrivacyService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.EraseDue(ctx); err != nil {
+					log.Printf("Failed to erase personal data: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Export collects the profile, sessions, audit trail and shipments of the
+// user
+func (s *PrivacyService) Export(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	erasure, err := s.privacyRepo.GetErasure(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.families.ListForUser(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog, err := s.auditTrail(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	shipments, err := s.privacyRepo.ListShipments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DataExport{
+		User:       user,
+		Erasure:    erasure,
+		Sessions:   sessions,
+		AuditLog:   auditLog,
+		Shipments:  shipments,
+		ExportedAt: time.Now(),
+	}, nil
+}
+
+// ExportManagedUser exports the data of a user of a tenant the caller
+// manages
+func (s *PrivacyService) ExportManagedUser(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	if err := s.checkManaged(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.Export(ctx, userID)
+}
+
+// RequestErasure schedules the erasure of the user's personal data after
+// the grace period. The account keeps working until then, so the user can
+// still cancel.
+func (s *PrivacyService) RequestErasure(ctx context.Context, userID uuid.UUID) (*models.Erasure, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.IsPlatformUser() {
+		// Platform identities are removed by operators, not by erasure
+		return nil, ErrUserNotFound
+	}
+
+	scheduledAt := time.Now().Add(s.gracePeriod)
+	scheduled, err := s.privacyRepo.ScheduleErasure(ctx, userID, scheduledAt)
+	if err != nil {
+		return nil, err
+	}
+
+	erasure, err := s.privacyRepo.GetErasure(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !scheduled {
+		if erasure != nil && erasure.AnonymizedAt != nil {
+			return nil, ErrAccountAnonymized
+		}
+		return nil, ErrErasurePending
+	}
+
+	s.recordErasure(ctx, AuditActionErasureRequested, user, map[string]interface{}{"scheduled_at": scheduledAt})
+	return erasure, nil
+}
+
+// RequestErasureForManagedUser schedules the erasure of a user of a tenant
+// the caller manages
+func (s *PrivacyService) RequestErasureForManagedUser(ctx context.Context, userID uuid.UUID) (*models.Erasure, error) {
+	if err := s.checkManaged(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.RequestErasure(ctx, userID)
+}
+
+// CancelErasure withdraws a pending erasure request of the user
+func (s *PrivacyService) CancelErasure(ctx context.Context, userID uuid.UUID) error {
+	if err := rejectImpersonation(ctx); err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	cancelled, err := s.privacyRepo.CancelErasure(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrNoErasurePending
+	}
+
+	s.recordErasure(ctx, AuditActionErasureCancelled, user, nil)
+	return nil
+}
+
+// CancelErasureForManagedUser withdraws the pending erasure of a user of a
+// tenant the caller manages
+func (s *PrivacyService) CancelErasureForManagedUser(ctx context.Context, userID uuid.UUID) error {
+	if err := s.checkManaged(ctx, userID); err != nil {
+		return err
+	}
+	return s.CancelErasure(ctx, userID)
+}
+
+// EraseDue anonymizes every user whose grace period has ended and ends
+// their sessions
+func (s *PrivacyService) EraseDue(ctx context.Context) error {
+	for {
+		due, err := s.privacyRepo.ListDueErasures(database.WithPlatformScope(ctx), time.Now(), erasureBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range due {
+			if err := s.anonymize(tenantScope(ctx, user.TenantID), user); err != nil {
+				return err
+			}
+		}
+
+		if len(due) < erasureBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *PrivacyService) anonymize(ctx context.Context, user *models.User) error {
+	anonymized, err := s.privacyRepo.Anonymize(ctx, user.ID)
+	if err != nil || !anonymized {
+		return err
+	}
+
+	if err := s.denylist.RevokeAllForUser(ctx, user.ID.String(), refreshTokenTTL); err != nil {
+		return err
+	}
+	if err := s.families.RevokeAllForUser(ctx, user.ID.String()); err != nil {
+		return err
+	}
+
+	s.recordErasure(ctx, AuditActionUserAnonymized, user, nil)
+	return nil
+}
+
+// auditTrail returns the entries about the user and those of actions the
+// user took, newest first
+func (s *PrivacyService) auditTrail(ctx context.Context, user *models.User) ([]*models.AuditEntry, error) {
+	seen := map[uuid.UUID]bool{}
+	entries := []*models.AuditEntry{}
+	collect := func(entry *models.AuditEntry) error {
+		if !seen[entry.ID] {
+			seen[entry.ID] = true
+			entries = append(entries, entry)
+		}
+		return nil
+	}
+
+	if err := s.auditRepo.Each(ctx, &models.AuditFilter{ResourceID: &user.ID}, collect); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Each(ctx, &models.AuditFilter{ActorID: &user.ID}, collect); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+
+	return entries, nil
+}
+
+func (s *PrivacyService) recordErasure(ctx context.Context, action string, user *models.User, values map[string]interface{}) {
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(user.TenantID),
+		Action:       action,
+		ResourceType: AuditResourceUser,
+		ResourceID:   &user.ID,
+		NewValues:    values,
+	})
+}
+
+func (s *PrivacyService) checkManaged(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !canManageTenant(ctx, user.TenantID) {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// WriteDataExportArchive writes the export as a zip archive with one JSON
+// file per kind of record
+func WriteDataExportArchive(w io.Writer, export *models.DataExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", map[string]interface{}{"user": export.User, "erasure": export.Erasure, "exported_at": export.ExportedAt}},
+		{"sessions.json", export.Sessions},
+		{"audit_log.json", export.AuditLog},
+		{"shipments.json", export.Shipments},
+	}
+
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"auth-service/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	refreshFamilyPrefix     = "refresh_family:"
+	userRefreshFamilyPrefix = "user_refresh_families:"
+)
+
+var ErrRefreshFamilyNotFound = errors.New("refresh token family not found")
+
+// rotateFamilyScript swaps the current refresh jti of a family only if the
+// presented jti is still the current one, and records when the session was
+// last used. The user's set of families lives as long as its newest family.
+var rotateFamilyScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], "jti")
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "jti", ARGV[2], "last_used_at", ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+if redis.call("PTTL", KEYS[2]) < tonumber(ARGV[3]) then
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+end
+return 1
+`)
+
+// RefreshFamilyStore tracks refresh token families in Redis. A family is
+// created per login session and always has exactly one valid refresh token.
+// The family hash also holds the session metadata shown to users.
+type RefreshFamilyStore struct {
+	redis *redis.Client
+}
+
+func NewRefreshFamilyStore(redis *redis.Client) *RefreshFamilyStore {
+	return &RefreshFamilyStore{redis: redis}
+}
+
+// Create starts a new family for the session with its first refresh token.
+// The family ID is the session ID.
+func (s *RefreshFamilyStore) Create(ctx context.Context, session *models.Session, jti string, ttl time.Duration) error {
+	familyKey := refreshFamilyPrefix + session.ID
+	userKey := userRefreshFamilyPrefix + session.UserID
+
+	now := time.Now()
+	session.CreatedAt = now
+	session.LastUsedAt = now
+
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, familyKey,
+		"user_id", session.UserID,
+		"jti", jti,
+		"tenant_id", session.TenantID,
+		"client_id", session.ClientID,
+		"device_name", session.DeviceName,
+		"user_agent", session.UserAgent,
+		"ip", session.IP,
+		"created_at", now.Unix(),
+		"last_used_at", now.Unix(),
+	)
+	pipe.Expire(ctx, familyKey, ttl)
+	pipe.SAdd(ctx, userKey, session.ID)
+	pipe.Expire(ctx, userKey, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Rotate replaces oldJTI with newJTI. It returns false if oldJTI is not
+// the current token of the family, which means the token was reused.
+func (s *RefreshFamilyStore) Rotate(ctx context.Context, userID, familyID, oldJTI, newJTI string, ttl time.Duration) (bool, error) {
+	result, err := rotateFamilyScript.Run(ctx, s.redis,
+		[]string{refreshFamilyPrefix + familyID, userRefreshFamilyPrefix + userID},
+		oldJTI, newJTI, ttl.Milliseconds(), time.Now().Unix(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+
+	switch result {
+	case -1:
+		return false, ErrRefreshFamilyNotFound
+	case 0:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Revoke deletes the family so none of its tokens can be refreshed
+func (s *RefreshFamilyStore) Revoke(ctx context.Context, familyID string) error {
+	userID, err := s.redis.HGet(ctx, refreshFamilyPrefix+familyID, "user_id").Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, refreshFamilyPrefix+familyID)
+	if userID != "" {
+		pipe.SRem(ctx, userRefreshFamilyPrefix+userID, familyID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RevokeAllForUser deletes every family of the user
+func (s *RefreshFamilyStore) RevokeAllForUser(ctx context.Context, userID string) error {
+	userKey := userRefreshFamilyPrefix + userID
+
+	familyIDs, err := s.redis.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userKey}
+	for _, familyID := range familyIDs {
+		keys = append(keys, refreshFamilyPrefix+familyID)
+	}
+
+	return s.redis.Del(ctx, keys...).Err()
+}
+
+// Get returns the session of a family, or nil if it has ended
+func (s *RefreshFamilyStore) Get(ctx context.Context, familyID string) (*models.Session, error) {
+	fields, err := s.redis.HGetAll(ctx, refreshFamilyPrefix+familyID).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return sessionFromFields(familyID, fields), nil
+}
+
+// ListForUser returns the active sessions of the user and forgets families
+// that have expired
+func (s *RefreshFamilyStore) ListForUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	userKey := userRefreshFamilyPrefix + userID
+
+	familyIDs, err := s.redis.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.redis.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(familyIDs))
+	for i, familyID := range familyIDs {
+		cmds[i] = pipe.HGetAll(ctx, refreshFamilyPrefix+familyID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	sessions := []*models.Session{}
+	var expired []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			expired = append(expired, familyIDs[i])
+			continue
+		}
+		sessions = append(sessions, sessionFromFields(familyIDs[i], fields))
+	}
+
+	if len(expired) > 0 {
+		s.redis.SRem(ctx, userKey, expired...)
+	}
+
+	return sessions, nil
+}
+
+func sessionFromFields(familyID string, fields map[string]string) *models.Session {
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	lastUsedAt, _ := strconv.ParseInt(fields["last_used_at"], 10, 64)
+
+	return &models.Session{
+		ID:         familyID,
+		UserID:     fields["user_id"],
+		TenantID:   fields["tenant_id"],
+		ClientID:   fields["client_id"],
+		DeviceName: fields["device_name"],
+		UserAgent:  fields["user_agent"],
+		IP:         fields["ip"],
+		CreatedAt:  time.Unix(createdAt, 0),
+		LastUsedAt: time.Unix(lastUsedAt, 0),
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleNameReserved  = errors.New("role name is reserved for a built-in role")
+	ErrRoleNameTaken     = errors.New("role with this name already exists")
+	ErrInvalidPermission = errors.New("unknown or non-grantable permission")
+)
+
+// RoleService resolves effective permissions and manages the custom roles
+// of a tenant. Changes that can take permissions away revoke the tokens of
+// the affected users.
+type RoleService struct {
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+	audit    *AuditService
+	families *RefreshFamilyStore
+	denylist *TokenDenylist
+}
+
+func NewRoleService(roleRepo repository.RoleRepository, userRepo repository.UserRepository, audit *AuditService, redis *redis.Client) *RoleService {
+	return &RoleService{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		audit:    audit,
+		families: NewRefreshFamilyStore(redis),
+		denylist: NewTokenDenylist(redis),
+	}
+}
+
+// PermissionsForUser returns the permissions of the user's built-in role
+// merged with those of their custom role
+func (s *RoleService) PermissionsForUser(ctx context.Context, user *models.User) ([]string, error) {
+	custom, err := s.roleRepo.GetForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if custom == nil {
+		return mergePermissions(RolePermissions(user.Role)), nil
+	}
+
+	return mergePermissions(RolePermissions(user.Role), custom.Permissions), nil
+}
+
+func (s *RoleService) ListRoles(ctx context.Context, tenantID uuid.UUID) ([]*models.Role, error) {
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrRoleNotFound
+	}
+	return s.roleRepo.ListByTenant(ctx, tenantID)
+}
+
+func (s *RoleService) CreateRole(ctx context.Context, tenantID uuid.UUID, req *models.RoleRequest) (*models.Role, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrRoleNotFound
+	}
+
+	role := &models.Role{
+		TenantID:    tenantID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	if err := validateRole(role); err != nil {
+		return nil, err
+	}
+	if !callerHolds(ctx, role.Permissions) {
+		return nil, ErrPermissionNotHeld
+	}
+
+	if err := s.roleRepo.Create(ctx, role); err != nil {
+		return nil, translateRoleError(err)
+	}
+
+	s.recordRole(ctx, AuditActionRoleCreated, nil, role)
+	return role, nil
+}
+
+func (s *RoleService) UpdateRole(ctx context.Context, tenantID, id uuid.UUID, req *models.RoleRequest) (*models.Role, error) {
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrRoleNotFound
+	}
+
+	existing, err := s.roleRepo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrRoleNotFound
+	}
+
+	before := *existing
+
+	existing.Name = strings.TrimSpace(req.Name)
+	existing.Description = req.Description
+	existing.Permissions = req.Permissions
+	if err := validateRole(existing); err != nil {
+		return nil, err
+	}
+	if !callerHolds(ctx, existing.Permissions) {
+		return nil, ErrPermissionNotHeld
+	}
+
+	updated, err := s.roleRepo.Update(ctx, existing)
+	if err != nil {
+		return nil, translateRoleError(err)
+	}
+	if !updated {
+		return nil, ErrRoleNotFound
+	}
+
+	s.recordRole(ctx, AuditActionRoleUpdated, &before, existing)
+
+	// Holders keep working until they sign in again when nothing was taken
+	// away
+	for _, permission := range before.Permissions {
+		if !containsString(existing.Permissions, permission) {
+			if err := s.revokeHolders(ctx, tenantID, id); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	return existing, nil
+}
+
+func (s *RoleService) DeleteRole(ctx context.Context, tenantID, id uuid.UUID) error {
+	if err := rejectImpersonation(ctx); err != nil {
+		return err
+	}
+
+	if !canManageTenant(ctx, tenantID) {
+		return ErrRoleNotFound
+	}
+
+	existing, err := s.roleRepo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrRoleNotFound
+	}
+
+	holders, err := s.roleRepo.ListHolders(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.roleRepo.Delete(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRoleNotFound
+	}
+
+	s.recordRole(ctx, AuditActionRoleDeleted, existing, nil)
+	return revokeUserTokens(ctx, s.denylist, s.families, holders...)
+}
+
+// AssignRole sets or clears the custom role of a user. Only roles whose
+// permissions the caller holds can be assigned. The user's tokens are
+// revoked, the new permissions apply once they sign in again.
+func (s *RoleService) AssignRole(ctx context.Context, userID uuid.UUID, roleID *uuid.UUID) error {
+	if err := rejectImpersonation(ctx); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !canManageTenant(ctx, user.TenantID) {
+		return ErrUserNotFound
+	}
+
+	previous, err := s.roleRepo.GetForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if roleID != nil {
+		role, err := s.roleRepo.GetByID(ctx, user.TenantID, *roleID)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			return ErrRoleNotFound
+		}
+		if !callerHolds(ctx, role.Permissions) {
+			return ErrPermissionNotHeld
+		}
+	}
+
+	assigned, err := s.roleRepo.AssignToUser(ctx, user.TenantID, userID, roleID)
+	if err != nil {
+		return err
+	}
+	if !assigned {
+		return ErrUserNotFound
+	}
+
+	var previousID *uuid.UUID
+	if previous != nil {
+		previousID = &previous.ID
+	}
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(user.TenantID),
+		Action:       AuditActionRoleChanged,
+		ResourceType: AuditResourceUser,
+		ResourceID:   &user.ID,
+		OldValues:    map[string]interface{}{"role_id": previousID},
+		NewValues:    map[string]interface{}{"role_id": roleID},
+	})
+
+	return revokeUserTokens(ctx, s.denylist, s.families, user.ID)
+}
+
+// revokeHolders revokes the tokens of every user the role is assigned to
+func (s *RoleService) revokeHolders(ctx context.Context, tenantID, id uuid.UUID) error {
+	holders, err := s.roleRepo.ListHolders(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	return revokeUserTokens(ctx, s.denylist, s.families, holders...)
+}
+
+// recordRole audits a change to a custom role
+func (s *RoleService) recordRole(ctx context.Context, action string, before, after *models.Role) {
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     auditTenantID(subject.TenantID),
+		Action:       action,
+		ResourceType: AuditResourceRole,
+		ResourceID:   &subject.ID,
+		OldValues:    auditRoleValues(before),
+		NewValues:    auditRoleValues(after),
+	})
+}
+
+func auditRoleValues(role *models.Role) map[string]interface{} {
+	if role == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"name":        role.Name,
+		"description": role.Description,
+		"permissions": role.Permissions,
+	}
+}
+
+func validateRole(role *models.Role) error {
+	if IsBuiltinRole(role.Name) {
+		return ErrRoleNameReserved
+	}
+
+	grantable := TenantPermissions()
+	for _, permission := range role.Permissions {
+		if !containsString(grantable, permission) {
+			return ErrInvalidPermission
+		}
+	}
+
+	role.Permissions = mergePermissions(role.Permissions)
+	return nil
+}
+
+func translateRoleError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrRoleNameTaken
+	}
+	return err
+}
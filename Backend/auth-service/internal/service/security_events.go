@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const securityEventsChannel = "security_events"
+
+// Security event types
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+)
+
+type SecurityEvent struct {
+	Type       string            `json:"type"`
+	UserID     string            `json:"user_id"`
+	TenantID   string            `json:"tenant_id"`
+	Details    map[string]string `json:"details,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// SecurityEventPublisher delivers security events to interested consumers
+type SecurityEventPublisher interface {
+	Publish(ctx context.Context, event *SecurityEvent) error
+}
+
+type redisSecurityEventPublisher struct {
+	redis *redis.Client
+}
+
+// NewSecurityEventPublisher publishes events to the security_events Redis channel
+func NewSecurityEventPublisher(redis *redis.Client) SecurityEventPublisher {
+	return &redisSecurityEventPublisher{redis: redis}
+}
+
+func (p *redisSecurityEventPublisher) Publish(ctx context.Context, event *SecurityEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("⚠️  Security event: %s", payload)
+
+	return p.redis.Publish(ctx, securityEventsChannel, payload).Err()
+}
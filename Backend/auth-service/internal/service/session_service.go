@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService lists and ends the login sessions of users. A session is a
+// refresh token family; ending it also revokes its access tokens.
+type SessionService struct {
+	userRepo repository.UserRepository
+	families *RefreshFamilyStore
+	denylist *TokenDenylist
+}
+
+func NewSessionService(userRepo repository.UserRepository, redis *redis.Client) *SessionService {
+	return &SessionService{
+		userRepo: userRepo,
+		families: NewRefreshFamilyStore(redis),
+		denylist: NewTokenDenylist(redis),
+	}
+}
+
+// ListForUser returns the active sessions of the user, most recently used
+// first. The session of the caller is marked as current.
+func (s *SessionService) ListForUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	sessions, err := s.families.ListForUser(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	currentSessionID := ""
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		currentSessionID = claims.SessionID
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// Revoke ends a session of the user
+func (s *SessionService) Revoke(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	session, err := s.families.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID.String() {
+		return ErrSessionNotFound
+	}
+
+	if err := s.denylist.RevokeSession(ctx, sessionID, accessTokenTTL); err != nil {
+		return err
+	}
+
+	return s.families.Revoke(ctx, sessionID)
+}
+
+// ListForManagedUser lists the sessions of a user of a tenant the caller
+// manages
+func (s *SessionService) ListForManagedUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	if err := s.checkManaged(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.ListForUser(ctx, userID)
+}
+
+// RevokeForManagedUser ends a session of a user of a tenant the caller
+// manages
+func (s *SessionService) RevokeForManagedUser(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	if err := s.checkManaged(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.Revoke(ctx, userID, sessionID)
+}
+
+// RevokeAllForManagedUser ends every session of a user of a tenant the
+// caller manages, including the outstanding access tokens
+func (s *SessionService) RevokeAllForManagedUser(ctx context.Context, userID uuid.UUID) error {
+	if err := s.checkManaged(ctx, userID); err != nil {
+		return err
+	}
+
+	return revokeUserTokens(ctx, s.denylist, s.families, userID)
+}
+
+// revokeUserTokens ends every session of the users and revokes their access
+// tokens. Permissions are baked into access tokens, so changes that take
+// rights away must revoke the tokens already issued.
+func revokeUserTokens(ctx context.Context, denylist *TokenDenylist, families *RefreshFamilyStore, userIDs ...uuid.UUID) error {
+	for _, userID := range userIDs {
+		if err := denylist.RevokeAllForUser(ctx, userID.String(), refreshTokenTTL); err != nil {
+			return err
+		}
+		if err := families.RevokeAllForUser(ctx, userID.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SessionService) checkManaged(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !canManageTenant(ctx, user.TenantID) {
+		return ErrUserNotFound
+	}
+	return nil
+}
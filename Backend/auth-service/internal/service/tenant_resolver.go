@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	tenantCachePrefix     = "tenant_cache:"
+	tenantCacheKeysPrefix = "tenant_cache_keys:"
+
+	// TenantInvalidationChannel receives the ID of every tenant that was
+	// created or changed. config-service publishes to it.
+	TenantInvalidationChannel = "tenant_invalidations"
+
+	// Unknown hosts are remembered briefly so host scans do not reach the
+	// database
+	tenantMissCacheTTL = 30 * time.Second
+	tenantMissMarker   = "-"
+
+	// Entries of the in-process cache live shorter than the Redis ones, in
+	// case an invalidation message is lost
+	tenantLocalCacheTTL = 30 * time.Second
+)
+
+var (
+	ErrTenantNotFound  = errors.New("unknown tenant")
+	ErrTenantSuspended = errors.New("tenant is suspended")
+)
+
+// reservedSubdomains belong to the platform itself
+var reservedSubdomains = map[string]bool{
+	"www":   true,
+	"api":   true,
+	"auth":  true,
+	"admin": true,
+}
+
+// RequestTenant is the tenant a request is addressed to
+type RequestTenant struct {
+	ID        uuid.UUID `json:"id"`
+	Subdomain string    `json:"subdomain"`
+	Tier      string    `json:"tier"`
+	Status    string    `json:"status"`
+}
+
+type requestTenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the resolved tenant
+func ContextWithTenant(ctx context.Context, tenant *RequestTenant) context.Context {
+	return context.WithValue(ctx, requestTenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored by ContextWithTenant
+func TenantFromContext(ctx context.Context) (*RequestTenant, bool) {
+	tenant, ok := ctx.Value(requestTenantContextKey{}).(*RequestTenant)
+	return tenant, ok
+}
+
+type localTenantEntry struct {
+	tenant  *RequestTenant // nil for unknown tenants
+	expires time.Time
+}
+
+// TenantResolver maps request hosts and tenant IDs to tenants. Lookups are
+// cached in process and in Redis; Invalidate drops both on every instance.
+type TenantResolver struct {
+	tenantRepo  repository.TenantRepository
+	redis       *redis.Client
+	baseDomains []string
+	cacheTTL    time.Duration
+
+	mu    sync.RWMutex
+	local map[string]localTenantEntry
+}
+
+func NewTenantResolver(tenantRepo repository.TenantRepository, redis *redis.Client, cfg *config.Config) *TenantResolver {
+	var baseDomains []string
+	for _, domain := range strings.Split(cfg.TenantBaseDomains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			baseDomains = append(baseDomains, domain)
+		}
+	}
+
+	return &TenantResolver{
+		tenantRepo:  tenantRepo,
+		redis:       redis,
+		baseDomains: baseDomains,
+		cacheTTL:    cfg.TenantCacheTTL,
+		local:       map[string]localTenantEntry{},
+	}
+}
+
+// Start listens for tenant invalidations published by other instances and
+// services until ctx is cancelled
+func (r *TenantResolver) Start(ctx context.Context) {
+	pubsub := r.redis.Subscribe(ctx, TenantInvalidationChannel)
+
+	go func() {
+		defer pubsub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-pubsub.Channel():
+				if !ok {
+					return
+				}
+				tenantID, err := uuid.Parse(msg.Payload)
+				if err != nil {
+					continue
+				}
+				if err := r.dropCached(ctx, tenantID); err != nil {
+					log.Printf("Failed to invalidate tenant %s: %v", tenantID, err)
+				}
+			}
+		}
+	}()
+}
+
+// ResolveHost returns the tenant served on the host, by subdomain of one of
+// the base domains or by custom domain. It returns nil for hosts of the
+// platform itself.
+func (r *TenantResolver) ResolveHost(ctx context.Context, host string) (*RequestTenant, error) {
+	subdomain, customDomain := r.parseHost(host)
+
+	switch {
+	case subdomain != "":
+		return r.resolve(ctx, "subdomain:"+subdomain, func() (*models.Tenant, error) {
+			return r.tenantRepo.GetBySubdomain(ctx, subdomain)
+		})
+	case customDomain != "":
+		return r.resolve(ctx, "domain:"+customDomain, func() (*models.Tenant, error) {
+			return r.tenantRepo.GetByCustomDomain(ctx, customDomain)
+		})
+	default:
+		return nil, nil
+	}
+}
+
+// ResolveID returns the tenant with the given ID
+func (r *TenantResolver) ResolveID(ctx context.Context, tenantID uuid.UUID) (*RequestTenant, error) {
+	return r.resolve(ctx, "id:"+tenantID.String(), func() (*models.Tenant, error) {
+		return r.tenantRepo.GetByID(ctx, tenantID)
+	})
+}
+
+// Invalidate drops the cached lookups of the tenant on every instance. Call
+// it after changing the tenant's domains, tier or status.
+func (r *TenantResolver) Invalidate(ctx context.Context, tenantID uuid.UUID) error {
+	if err := r.dropCached(ctx, tenantID); err != nil {
+		return err
+	}
+	return r.redis.Publish(ctx, TenantInvalidationChannel, tenantID.String()).Err()
+}
+
+// resolve looks the tenant up by cache key and rejects tenants that may
+// not be served
+func (r *TenantResolver) resolve(ctx context.Context, key string, load func() (*models.Tenant, error)) (*RequestTenant, error) {
+	tenant, err := r.lookup(ctx, key, load)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case tenant == nil:
+		return nil, ErrTenantNotFound
+	case tenant.Status == "suspended":
+		return nil, ErrTenantSuspended
+	case tenant.Status != "active":
+		return nil, ErrTenantNotFound
+	}
+
+	return tenant, nil
+}
+
+func (r *TenantResolver) lookup(ctx context.Context, key string, load func() (*models.Tenant, error)) (*RequestTenant, error) {
+	r.mu.RLock()
+	entry, ok := r.local[key]
+	r.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.tenant, nil
+	}
+
+	cached, err := r.redis.Get(ctx, tenantCachePrefix+key).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if err == nil {
+		var tenant *RequestTenant
+		if cached != tenantMissMarker {
+			tenant = &RequestTenant{}
+			if err := json.Unmarshal([]byte(cached), tenant); err != nil {
+				return nil, err
+			}
+		}
+		r.storeLocal(key, tenant)
+		return tenant, nil
+	}
+
+	found, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		r.redis.Set(ctx, tenantCachePrefix+key, tenantMissMarker, tenantMissCacheTTL)
+		r.storeLocal(key, nil)
+		return nil, nil
+	}
+
+	tenant := &RequestTenant{
+		ID:        found.ID,
+		Subdomain: found.Subdomain,
+		Tier:      found.Tier,
+		Status:    found.Status,
+	}
+
+	data, err := json.Marshal(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the key under the tenant so Invalidate finds it even after
+	// the tenant's domains changed
+	keysKey := tenantCacheKeysPrefix + found.ID.String()
+	pipe := r.redis.TxPipeline()
+	pipe.Set(ctx, tenantCachePrefix+key, data, r.cacheTTL)
+	pipe.SAdd(ctx, keysKey, key)
+	pipe.Expire(ctx, keysKey, r.cacheTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	r.storeLocal(key, tenant)
+	return tenant, nil
+}
+
+func (r *TenantResolver) storeLocal(key string, tenant *RequestTenant) {
+	ttl := tenantLocalCacheTTL
+	if r.cacheTTL < ttl {
+		ttl = r.cacheTTL
+	}
+
+	r.mu.Lock()
+	r.local[key] = localTenantEntry{tenant: tenant, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+}
+
+// dropCached removes the tenant's Redis entries, including remembered
+// misses for its current domains, and empties the in-process cache
+func (r *TenantResolver) dropCached(ctx context.Context, tenantID uuid.UUID) error {
+	keysKey := tenantCacheKeysPrefix + tenantID.String()
+
+	keys, err := r.redis.SMembers(ctx, keysKey).Result()
+	if err != nil {
+		return err
+	}
+
+	toDelete := []string{keysKey, tenantCachePrefix + "id:" + tenantID.String()}
+	for _, key := range keys {
+		toDelete = append(toDelete, tenantCachePrefix+key)
+	}
+
+	tenant, err := r.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if tenant != nil {
+		toDelete = append(toDelete, tenantCachePrefix+"subdomain:"+strings.ToLower(tenant.Subdomain))
+		if tenant.CustomDomain != nil {
+			toDelete = append(toDelete, tenantCachePrefix+"domain:"+strings.ToLower(*tenant.CustomDomain))
+		}
+	}
+
+	if err := r.redis.Del(ctx, toDelete...).Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.local = map[string]localTenantEntry{}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// parseHost splits a request host into a tenant subdomain of a base domain
+// or a custom domain. Hosts of the platform, IPs and single-label hosts such
+// as internal service names yield neither.
+func (r *TenantResolver) parseHost(host string) (subdomain, customDomain string) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	if host == "" || net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return "", ""
+	}
+
+	for _, base := range r.baseDomains {
+		if host == base {
+			return "", ""
+		}
+		if strings.HasSuffix(host, "."+base) {
+			label := strings.TrimSuffix(host, "."+base)
+			if strings.Contains(label, ".") || reservedSubdomains[label] {
+				return "", ""
+			}
+			return label, ""
+		}
+	}
+
+	return "", host
+}
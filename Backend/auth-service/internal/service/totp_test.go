@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// SHA-1 test vectors of RFC 6238 appendix B, truncated to totpDigits
+var totpVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+var totpTestKey = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, tc := range totpVectors {
+		step := tc.unix / int64(totpPeriod.Seconds())
+		want := tc.code[len(tc.code)-totpDigits:]
+		if got := totpCode(totpTestKey, step); got != want {
+			t.Errorf("totpCode at %d = %s, want %s", tc.unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(totpTestKey)
+
+	for _, tc := range totpVectors {
+		now := time.Unix(tc.unix, 0)
+		code := tc.code[len(tc.code)-totpDigits:]
+
+		step, ok := validateTOTP(secret, code, now)
+		if !ok {
+			t.Errorf("validateTOTP rejected the code at %d", tc.unix)
+			continue
+		}
+		if want := tc.unix / int64(totpPeriod.Seconds()); step != want {
+			t.Errorf("validateTOTP at %d matched step %d, want %d", tc.unix, step, want)
+		}
+
+		// Clock drift of one step either way is accepted, two is not
+		if _, ok := validateTOTP(secret, code, now.Add(totpPeriod)); !ok {
+			t.Errorf("validateTOTP rejected the code one step later at %d", tc.unix)
+		}
+		if _, ok := validateTOTP(secret, code, now.Add(-totpPeriod)); !ok {
+			t.Errorf("validateTOTP rejected the code one step earlier at %d", tc.unix)
+		}
+		if _, ok := validateTOTP(secret, code, now.Add(3*totpPeriod)); ok {
+			t.Errorf("validateTOTP accepted the code three steps later at %d", tc.unix)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformed(t *testing.T) {
+	secret := totpEncoding.EncodeToString(totpTestKey)
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870822", "000000"} {
+		if _, ok := validateTOTP(secret, code, now); ok {
+			t.Errorf("validateTOTP accepted %q", code)
+		}
+	}
+	if _, ok := validateTOTP("not base32!", "287082", now); ok {
+		t.Error("validateTOTP accepted a malformed secret")
+	}
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-service/internal/config"
+	"auth-service/internal/database"
+	"auth-service/internal/models"
+	"auth-service/internal/passhash"
+	"auth-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserImportFormatCSV   = "csv"
+	UserImportFormatJSONL = "jsonl"
+
+	UserImportStatusPending   = "pending"
+	UserImportStatusRunning   = "running"
+	UserImportStatusCompleted = "completed"
+	UserImportStatusFailed    = "failed"
+
+	// MaxUserImportSize bounds the uploaded file
+	MaxUserImportSize = 20 << 20
+
+	// Progress and row errors are stored after this many rows
+	userImportBatchSize = 100
+	userImportJobsLimit = 50
+)
+
+var (
+	ErrImportNotFound      = errors.New("import job not found")
+	ErrInvalidImportFile   = errors.New("invalid import file")
+	ErrImportRoleForbidden = errors.New("importing staff roles requires the roles:manage permission")
+)
+
+// UserImportService onboards the users of a tenant in bulk. Uploaded files
+// are stored as jobs and imported in the background: rows are validated one
+// by one, users already registered in the tenant are skipped, and rejected
+// rows are kept as a per-row report.
+type UserImportService struct {
+	importRepo    repository.UserImportRepository
+	userRepo      repository.UserRepository
+	passwordReset *PasswordResetService
+	audit         *AuditService
+	interval      time.Duration
+	staleAfter    time.Duration
+}
+
+func NewUserImportService(
+	importRepo repository.UserImportRepository,
+	userRepo repository.UserRepository,
+	passwordReset *PasswordResetService,
+	audit *AuditService,
+	cfg *config.Config,
+) *UserImportService {
+	return &UserImportService{
+		importRepo:    importRepo,
+		userRepo:      userRepo,
+		passwordReset: passwordReset,
+		audit:         audit,
+		interval:      cfg.UserImportInterval,
+		staleAfter:    cfg.UserImportStaleAfter,
+	}
+}
+
+// Start runs pending jobs, periodically until ctx is cancelled
+func (s *UserImportService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RunPending(ctx); err != nil {
+					log.Printf("Failed to run user imports: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// CreateJob checks the file and queues it for import into a tenant the
+// caller manages. Rows are validated later, only the file structure is
+// checked here.
+func (s *UserImportService) CreateJob(ctx context.Context, tenantID uuid.UUID, format string, sendInvitations bool, data []byte) (*models.UserImportJob, error) {
+	if !canManageTenant(ctx, tenantID) || tenantID == uuid.Nil {
+		return nil, ErrImportNotFound
+	}
+
+	records, err := parseUserImport(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, _ := ClaimsFromContext(ctx)
+	staff := HasPermission(claims.EffectivePermissions(), PermissionRolesManage)
+	for _, record := range records {
+		if record.row == nil {
+			continue
+		}
+		role := strings.TrimSpace(record.row.Role)
+		if role == "" || role == "customer" {
+			continue
+		}
+		if !staff {
+			return nil, ErrImportRoleForbidden
+		}
+		if !callerHolds(ctx, RolePermissions(role)) {
+			return nil, ErrPermissionNotHeld
+		}
+	}
+
+	job := &models.UserImportJob{
+		TenantID:        tenantID,
+		Format:          format,
+		SendInvitations: sendInvitations,
+		Status:          UserImportStatusPending,
+		Payload:         data,
+		TotalRows:       len(records),
+	}
+	if actorID, err := uuid.Parse(claims.UserID); err == nil {
+		job.CreatedBy = &actorID
+	}
+
+	if err := s.importRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetJob returns the progress of a job of a tenant the caller manages
+func (s *UserImportService) GetJob(ctx context.Context, tenantID, jobID uuid.UUID) (*models.UserImportJob, error) {
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrImportNotFound
+	}
+
+	job, err := s.importRepo.GetByID(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrImportNotFound
+	}
+	return job, nil
+}
+
+// ListJobs returns the latest jobs of a tenant the caller manages
+func (s *UserImportService) ListJobs(ctx context.Context, tenantID uuid.UUID) ([]*models.UserImportJob, error) {
+	if !canManageTenant(ctx, tenantID) {
+		return nil, ErrImportNotFound
+	}
+	return s.importRepo.ListByTenant(ctx, tenantID, userImportJobsLimit)
+}
+
+// ListErrors returns a page of the rejected rows of a job
+func (s *UserImportService) ListErrors(ctx context.Context, tenantID, jobID uuid.UUID, limit, offset int) ([]*models.UserImportError, error) {
+	if _, err := s.GetJob(ctx, tenantID, jobID); err != nil {
+		return nil, err
+	}
+	return s.importRepo.ListErrors(ctx, jobID, limit, offset)
+}
+
+// RunPending imports every queued job, one at a time
+func (s *UserImportService) RunPending(ctx context.Context) error {
+	for {
+		job, err := s.importRepo.ClaimNext(database.WithPlatformScope(ctx), time.Now().Add(-s.staleAfter))
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return nil
+		}
+
+		s.run(database.WithTenant(ctx, job.TenantID), job)
+	}
+}
+
+func (s *UserImportService) run(ctx context.Context, job *models.UserImportJob) {
+	err := s.importRows(ctx, job)
+	if ctx.Err() != nil {
+		// Shutting down, the job is picked up again once it goes stale
+		return
+	}
+
+	job.Status = UserImportStatusCompleted
+	if err != nil {
+		log.Printf("User import %s failed: %v", job.ID, err)
+		message := err.Error()
+		job.Status = UserImportStatusFailed
+		job.Error = &message
+	}
+
+	if err := s.importRepo.Finish(ctx, job); err != nil {
+		log.Printf("Failed to finish user import %s: %v", job.ID, err)
+		return
+	}
+
+	s.audit.Record(ctx, &models.AuditEntry{
+		TenantID:     &job.TenantID,
+		ActorID:      job.CreatedBy,
+		Action:       AuditActionUsersImported,
+		ResourceType: AuditResourceUserImport,
+		ResourceID:   &job.ID,
+		NewValues: map[string]interface{}{
+			"status":  job.Status,
+			"total":   job.TotalRows,
+			"created": job.CreatedRows,
+			"skipped": job.SkippedRows,
+			"failed":  job.FailedRows,
+		},
+	})
+}
+
+func (s *UserImportService) importRows(ctx context.Context, job *models.UserImportJob) error {
+	records, err := parseUserImport(job.Format, job.Payload)
+	if err != nil {
+		return err
+	}
+
+	var rejected []*models.UserImportError
+	flush := func() error {
+		if len(rejected) > 0 {
+			if err := s.importRepo.AddErrors(ctx, job, rejected); err != nil {
+				return err
+			}
+			rejected = rejected[:0]
+		}
+		return s.importRepo.UpdateProgress(ctx, job)
+	}
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		created, err := s.importRow(ctx, job, record)
+		switch {
+		case err != nil:
+			job.FailedRows++
+			email := ""
+			if record.row != nil {
+				email = record.row.Email
+			}
+			rejected = append(rejected, &models.UserImportError{Row: record.number, Email: email, Message: err.Error()})
+		case created:
+			job.CreatedRows++
+		default:
+			job.SkippedRows++
+		}
+		job.ProcessedRows++
+
+		if job.ProcessedRows%userImportBatchSize == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// importRow validates and creates one user. It reports false for users the
+// tenant already has.
+func (s *UserImportService) importRow(ctx context.Context, job *models.UserImportJob, record importRecord) (bool, error) {
+	if record.err != nil {
+		return false, record.err
+	}
+	row := record.row
+
+	user, err := userFromImportRow(job.TenantID, row)
+	if err != nil {
+		return false, err
+	}
+
+	created, err := s.userRepo.CreateIfNotExists(ctx, user)
+	if err != nil {
+		return false, fmt.Errorf("failed to create user: %w", err)
+	}
+	if !created {
+		return false, nil
+	}
+
+	if user.PasswordHash == "" && job.SendInvitations {
+		if err := s.passwordReset.SendInvitation(ctx, user); err != nil {
+			// The user exists, the invitation can be resent as a reset
+			log.Printf("Failed to send invitation to imported user %s: %v", user.ID, err)
+		}
+	}
+
+	return true, nil
+}
+
+// userFromImportRow validates the row. Users imported without a password
+// hash cannot sign in until they set a password.
+func userFromImportRow(tenantID uuid.UUID, row *models.UserImportRow) (*models.User, error) {
+	email := strings.TrimSpace(row.Email)
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, errors.New("invalid email")
+	}
+
+	role := strings.TrimSpace(row.Role)
+	if role == "" {
+		role = "customer"
+	}
+	if !assignableRoles[role] {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+
+	var phone *string
+	if row.Phone != nil && strings.TrimSpace(*row.Phone) != "" {
+		normalized, err := normalizePhone(*row.Phone)
+		if err != nil {
+			return nil, err
+		}
+		phone = &normalized
+	}
+
+	if row.PasswordHash != "" {
+		// Hashes of other systems are kept and upgraded on first login
+		if !passhash.Supported(row.PasswordHash) {
+			return nil, errors.New("password_hash must be a bcrypt, argon2 or Django PBKDF2 hash")
+		}
+	}
+
+	return &models.User{
+		TenantID:     tenantID,
+		Email:        email,
+		Phone:        phone,
+		PasswordHash: row.PasswordHash,
+		FirstName:    optionalImportField(row.FirstName),
+		LastName:     optionalImportField(row.LastName),
+		Role:         role,
+		IsVerified:   row.IsVerified,
+		IsActive:     true,
+	}, nil
+}
+
+func optionalImportField(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// importRecord is a row of an import file, or the reason it could not be
+// read
+type importRecord struct {
+	number int
+	row    *models.UserImportRow
+	err    error
+}
+
+// parseUserImport reads the rows of an import file. Malformed rows are
+// returned with an error, a malformed file fails as a whole.
+func parseUserImport(format string, data []byte) ([]importRecord, error) {
+	switch format {
+	case UserImportFormatCSV:
+		return parseUserImportCSV(data)
+	case UserImportFormatJSONL:
+		return parseUserImportJSONL(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImportFile, format)
+	}
+}
+
+// userImportColumns are the CSV columns, email is required
+var userImportColumns = map[string]bool{
+	"email": true, "phone": true, "first_name": true, "last_name": true,
+	"role": true, "password_hash": true, "is_verified": true,
+}
+
+func parseUserImportCSV(data []byte) ([]importRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header row", ErrInvalidImportFile)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !userImportColumns[name] {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImportFile, name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("%w: missing email column", ErrInvalidImportFile)
+	}
+
+	var records []importRecord
+	for number := 1; ; number++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+			}
+			records = append(records, importRecord{number: number, err: parseErr.Err})
+			continue
+		}
+		if len(fields) != len(header) {
+			records = append(records, importRecord{number: number, err: fmt.Errorf("expected %d fields, got %d", len(header), len(fields))})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return fields[i]
+			}
+			return ""
+		}
+		optional := func(name string) *string {
+			if value := field(name); value != "" {
+				return &value
+			}
+			return nil
+		}
+
+		row := &models.UserImportRow{
+			Email:        field("email"),
+			Phone:        optional("phone"),
+			FirstName:    optional("first_name"),
+			LastName:     optional("last_name"),
+			Role:         field("role"),
+			PasswordHash: field("password_hash"),
+		}
+		if raw := field("is_verified"); raw != "" {
+			verified, err := strconv.ParseBool(raw)
+			if err != nil {
+				records = append(records, importRecord{number: number, row: row, err: errors.New("invalid is_verified")})
+				continue
+			}
+			row.IsVerified = verified
+		}
+
+		records = append(records, importRecord{number: number, row: row})
+	}
+
+	return records, nil
+}
+
+func parseUserImportJSONL(data []byte) ([]importRecord, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), MaxUserImportSize)
+
+	var records []importRecord
+	number := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		number++
+
+		row := &models.UserImportRow{}
+		if err := json.Unmarshal(line, row); err != nil {
+			records = append(records, importRecord{number: number, err: errors.New("invalid JSON")})
+			continue
+		}
+		records = append(records, importRecord{number: number, row: row})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/internal/repository"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+	ErrRoleNotAssignable      = errors.New("role cannot be assigned to tenant users")
+	ErrRoleChangeForbidden    = errors.New("changing roles requires the roles:manage permission")
+	ErrInvalidCursor          = errors.New("invalid cursor")
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// assignableRoles are the built-in roles admins may give tenant users
+var assignableRoles = map[string]bool{
+	"tenant_admin": true,
+	"vendor":       true,
+	"operator":     true,
+	"customer":     true,
+}
+
+// UserService manages user profiles: users edit their own, admins those of
+// the tenants they manage. Every change is audited. Role changes and
+// deactivation revoke the user's tokens.
+type UserService struct {
+	userRepo  repository.UserRepository
+	passwords *PasswordPolicyService
+	audit     *AuditService
+	families  *RefreshFamilyStore
+	denylist  *TokenDenylist
+}
+
+func NewUserService(userRepo repository.UserRepository, passwords *PasswordPolicyService, audit *AuditService, redis *redis.Client) *UserService {
+	return &UserService{
+		userRepo:  userRepo,
+		passwords: passwords,
+		audit:     audit,
+		families:  NewRefreshFamilyStore(redis),
+		denylist:  NewTokenDenylist(redis),
+	}
+}
+
+// GetProfile returns the user's own account
+func (s *UserService) GetProfile(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userID uuid.UUID, req *models.UpdateUserRequest) (*models.User, error) {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, user, req, nil)
+}
+
+// ChangePassword sets a new password after checking the current one. The
+// new password must satisfy the tenant's password policy.
+func (s *UserService) ChangePassword(ctx context.Context, userID uuid.UUID, req *models.ChangePasswordRequest) error {
+	if err := rejectImpersonation(ctx); err != nil {
+		return err
+	}
+
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !s.passwords.Verify(user, req.OldPassword) {
+		return ErrInvalidCurrentPassword
+	}
+
+	if err := s.passwords.SetPassword(ctx, user, req.NewPassword); err != nil {
+		return err
+	}
+
+	s.audit.RecordUser(ctx, AuditActionPasswordChanged, user, user)
+	return nil
+}
+
+// SearchUsers returns a page of the matching users of a tenant the caller
+// manages, along with the cursor of the next page, "" on the last one
+func (s *UserService) SearchUsers(ctx context.Context, filter *models.UserFilter) ([]*models.User, string, error) {
+	if !canManageTenant(ctx, filter.TenantID) {
+		return nil, "", ErrUserNotFound
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUserPageSize
+	}
+	if filter.Limit > maxUserPageSize {
+		filter.Limit = maxUserPageSize
+	}
+
+	// One extra row tells whether another page follows
+	limit := filter.Limit
+	page := *filter
+	page.Limit = limit + 1
+
+	users, err := s.userRepo.Search(ctx, &page)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(users) <= limit {
+		return users, "", nil
+	}
+
+	users = users[:limit]
+	return users, encodeUserCursor(users[limit-1]), nil
+}
+
+// ExportUsers calls fn for every matching user of a tenant the caller
+// manages, ignoring the page limit
+func (s *UserService) ExportUsers(ctx context.Context, filter *models.UserFilter, fn func(user *models.User) error) error {
+	if !canManageTenant(ctx, filter.TenantID) {
+		return ErrUserNotFound
+	}
+
+	all := *filter
+	all.Limit = 0
+	return s.userRepo.Each(ctx, &all, fn)
+}
+
+// GetUser returns a user of a tenant the caller manages
+func (s *UserService) GetUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !canManageTenant(ctx, user.TenantID) {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// UpdateUser changes the profile and built-in role of a managed user. Role
+// changes need the roles:manage permission on top of users:write, and the
+// caller must hold every permission of the new role.
+func (s *UserService) UpdateUser(ctx context.Context, userID uuid.UUID, req *models.AdminUpdateUserRequest) (*models.User, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleChanged := req.Role != nil && *req.Role != user.Role
+	if roleChanged {
+		if err := rejectImpersonation(ctx); err != nil {
+			return nil, err
+		}
+		if user.IsPlatformUser() || !assignableRoles[*req.Role] {
+			return nil, ErrRoleNotAssignable
+		}
+		claims, _ := ClaimsFromContext(ctx)
+		if claims == nil || !HasPermission(claims.EffectivePermissions(), PermissionRolesManage) {
+			return nil, ErrRoleChangeForbidden
+		}
+		if !callerHolds(ctx, RolePermissions(*req.Role)) {
+			return nil, ErrPermissionNotHeld
+		}
+	}
+
+	updated, err := s.update(ctx, user, &req.UpdateUserRequest, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if roleChanged {
+		if err := revokeUserTokens(ctx, s.denylist, s.families, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
+// SetActive activates or deactivates a managed user
+func (s *UserService) SetActive(ctx context.Context, userID uuid.UUID, active bool) (*models.User, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsActive == active {
+		return user, nil
+	}
+
+	before := *user
+	user.IsActive = active
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	action := AuditActionUserDeactivated
+	if active {
+		action = AuditActionUserActivated
+	}
+	s.audit.RecordUser(ctx, action, &before, user)
+
+	if !active {
+		if err := revokeUserTokens(ctx, s.denylist, s.families, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserService) update(ctx context.Context, user *models.User, req *models.UpdateUserRequest, role *string) (*models.User, error) {
+	before := *user
+
+	if req.FirstName != nil {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = req.LastName
+	}
+	if req.Phone != nil {
+		user.Phone = req.Phone
+	}
+	if role != nil {
+		user.Role = *role
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	action := AuditActionUserUpdated
+	if user.Role != before.Role {
+		action = AuditActionRoleChanged
+	}
+	s.audit.RecordUser(ctx, action, &before, user)
+
+	return user, nil
+}
+
+// encodeUserCursor encodes the position of the user in search results as
+// an opaque token
+func encodeUserCursor(user *models.User) string {
+	raw := user.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + user.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseUserCursor decodes a cursor returned by SearchUsers
+func ParseUserCursor(cursor string) (*models.UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.UserCursor{CreatedAt: createdAt, ID: id}, nil
+}
@@ -0,0 +1,18 @@
+package sms
+
+import (
+	"context"
+	"log"
+)
+
+// LogSender writes messages to the service log. Intended for local development.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	log.Printf("📱 SMS to %s: %s", msg.To, msg.Body)
+	return nil
+}
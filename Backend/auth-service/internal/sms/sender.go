@@ -0,0 +1,29 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+
+	"auth-service/internal/config"
+)
+
+// Message is a plain text SMS
+type Message struct {
+	To   string // E.164 phone number
+	Body string
+}
+
+// Sender delivers text messages to phones
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewSender picks the sender implementation configured by SMS_DRIVER
+func NewSender(cfg *config.Config) (Sender, error) {
+	switch cfg.SMSDriver {
+	case "log", "":
+		return NewLogSender(), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS driver: %s", cfg.SMSDriver)
+	}
+}
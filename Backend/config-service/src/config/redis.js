@@ -73,6 +73,18 @@ export async function deleteCached(key) {
   }
 }
 
+// Tells services that cache tenant lookups (auth-service resolves tenants
+// from request hosts) to drop the tenant's cached entries
+export async function publishTenantInvalidation(tenantId) {
+  try {
+    await redisClient.publish('tenant_invalidations', String(tenantId));
+    return true;
+  } catch (error) {
+    logger.error('Redis publish error:', error);
+    return false;
+  }
+}
+
 export async function closeRedis() {
   if (redisClient) {
     await redisClient.quit();
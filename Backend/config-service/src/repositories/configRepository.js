@@ -1,5 +1,6 @@
 import { getDatabase } from '../config/database.js'
 import { logger } from '../utils/logger.js'
+import { publishTenantInvalidation } from '../config/redis.js'
 
 /**
  * Получить конфигурацию сайта из базы данных
@@ -281,6 +282,10 @@ export async function deleteTenant(tenantId) {
     
     const result = await db.query(query, [tenantId])
     
+    if (result.rows.length > 0) {
+      await publishTenantInvalidation(result.rows[0].id)
+    }
+    
     return result.rows.length > 0
   } catch (error) {
     logger.error(`Error deleting tenant ${tenantId}:`, error)
@@ -325,6 +330,8 @@ export async function updateTenantStatus(tenantId, status) {
     }
     
     const tenant = result.rows[0]
+    await publishTenantInvalidation(tenant.id)
+    
     return {
       tenantId: tenant.id,
       name: tenant.name,
@@ -376,6 +383,8 @@ export async function updateTenantTier(tenantId, tier) {
     }
     
     const tenant = result.rows[0]
+    await publishTenantInvalidation(tenant.id)
+    
     return {
       tenantId: tenant.id,
       name: tenant.name,
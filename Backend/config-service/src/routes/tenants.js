@@ -1,5 +1,6 @@
 import express from 'express';
 import { getDatabase } from '../config/database.js';
+import { publishTenantInvalidation } from '../config/redis.js';
 import { logger } from '../utils/logger.js';
 
 const router = express.Router();
@@ -166,6 +167,7 @@ router.post('/', async (req, res) => {
     `;
     
     await db.query(configQuery, [newTenant.id, name]);
+    await publishTenantInvalidation(newTenant.id);
     
     logger.info(`Created new tenant: ${subdomain} (${newTenant.id})`);
     
@@ -250,6 +252,8 @@ router.put('/:id', async (req, res) => {
       });
     }
     
+    await publishTenantInvalidation(id);
+    
     res.json({
       success: true,
       data: result.rows[0],
@@ -285,6 +289,8 @@ router.delete('/:id', async (req, res) => {
       });
     }
     
+    await publishTenantInvalidation(id);
+    
     res.json({
       success: true,
       message: 'Tenant deleted successfully',
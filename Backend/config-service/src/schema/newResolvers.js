@@ -1,7 +1,7 @@
 import { GraphQLError } from 'graphql';
 import { GraphQLJSON } from 'graphql-type-json';
 import { getPool } from '../config/database.js';
-import { getCached, setCached, deleteCached } from '../config/redis.js';
+import { getCached, setCached, deleteCached, publishTenantInvalidation } from '../config/redis.js';
 
 export const resolvers = {
   JSON: GraphQLJSON,
@@ -223,6 +223,7 @@ export const resolvers = {
       );
 
       await deleteCached(`tenant:${tenantId}`);
+      await publishTenantInvalidation(tenantId);
       return formatTenantConfig(result.rows[0]);
     },
 
@@ -1,7 +1,7 @@
 import { GraphQLError } from 'graphql';
 import { GraphQLJSON } from 'graphql-type-json';
 import { getPool } from '../config/database.js';
-import { getCached, setCached, deleteCached } from '../config/redis.js';
+import { getCached, setCached, deleteCached, publishTenantInvalidation } from '../config/redis.js';
 
 export const resolvers = {
   JSON: GraphQLJSON,
@@ -371,6 +371,7 @@ export const resolvers = {
 
       // Invalidate cache
       await deleteCached(`tenant:${tenantId}`);
+      await publishTenantInvalidation(tenantId);
 
       return formatTenantConfig(result.rows[0]);
     },
@@ -7,13 +7,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"logistics-service/internal/adapters"
+	"logistics-service/internal/auth"
 	"logistics-service/internal/config"
 	"logistics-service/internal/database"
 	"logistics-service/internal/handlers"
+	"logistics-service/internal/middleware"
 	"logistics-service/internal/repository"
 	"logistics-service/internal/service"
 
@@ -46,21 +49,28 @@ func main() {
 	boxberryAdapter := adapters.NewBoxberryAdapter(cfg.BoxberryAPIToken)
 	pickpointAdapter := adapters.NewPickPointAdapter(cfg.PickPointAPIKey)
 
-	// Initialize repositories
-	pvzRepo := repository.NewPVZRepository(db)
-	shipmentRepo := repository.NewShipmentRepository(db)
+	// Initialize repositories. pvz_locations and shipments have row-level
+	// security, queries run scoped to the tenant of the request.
+	tenantDB := database.NewTenantDB(db, cfg.DatabaseTenantRole)
+	pvzRepo := repository.NewPVZRepository(tenantDB)
+	shipmentRepo := repository.NewShipmentRepository(tenantDB)
 
 	// Initialize services
 	pvzService := service.NewPVZService(pvzRepo, redisClient, cdekAdapter, boxberryAdapter, pickpointAdapter)
 	shipmentService := service.NewShipmentService(shipmentRepo, pvzRepo, cdekAdapter, boxberryAdapter, pickpointAdapter)
 
+	// Access tokens are verified against the auth-service key set, API
+	// keys against the api_keys table auth-service manages
+	verifier := auth.NewVerifier(cfg.AuthJWKSURL, cfg.AuthIssuer, redisClient)
+	apiKeyVerifier := auth.NewAPIKeyVerifier(tenantDB)
+
 	// Initialize handlers
 	pvzHandler := handlers.NewPVZHandler(pvzService)
 	shipmentHandler := handlers.NewShipmentHandler(shipmentService)
 	webhookHandler := handlers.NewWebhookHandler(shipmentService)
 
 	// Setup router
-	router := setupRouter(cfg, pvzHandler, shipmentHandler, webhookHandler)
+	router := setupRouter(cfg, pvzHandler, shipmentHandler, webhookHandler, verifier, apiKeyVerifier)
 
 	// Start server
 	srv := &http.Server{
@@ -91,12 +101,17 @@ func main() {
 	log.Println("Server exited")
 }
 
-func setupRouter(cfg *config.Config, pvzHandler *handlers.PVZHandler, shipmentHandler *handlers.ShipmentHandler, webhookHandler *handlers.WebhookHandler) *gin.Engine {
+func setupRouter(cfg *config.Config, pvzHandler *handlers.PVZHandler, shipmentHandler *handlers.ShipmentHandler, webhookHandler *handlers.WebhookHandler, verifier *auth.Verifier, apiKeyVerifier *auth.APIKeyVerifier) *gin.Engine {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
+	// X-Forwarded-For is only believed from the configured proxies, otherwise
+	// any client could pick the IP that allow-lists and rate limits see
+	if err := router.SetTrustedProxies(splitList(cfg.TrustedProxies)); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
@@ -119,17 +134,18 @@ func setupRouter(cfg *config.Config, pvzHandler *handlers.PVZHandler, shipmentHa
 			pvz.GET("/:id", pvzHandler.GetPVZ)
 			pvz.POST("/search", pvzHandler.SearchPVZ)
 			pvz.POST("/calculate-tariff", pvzHandler.CalculateTariff)
-			pvz.POST("/sync", pvzHandler.SyncPVZFromProviders)
+			pvz.POST("/sync", middleware.AuthMiddleware(verifier, apiKeyVerifier), middleware.RequirePermission(auth.PermissionPVZSync), pvzHandler.SyncPVZFromProviders)
 		}
 
 		// Shipment routes
 		shipments := v1.Group("/shipments")
+		shipments.Use(middleware.AuthMiddleware(verifier, apiKeyVerifier))
 		{
-			shipments.POST("", shipmentHandler.CreateShipment)
-			shipments.GET("/:id", shipmentHandler.GetShipment)
+			shipments.POST("", middleware.RequirePermission(auth.PermissionShipmentsCreate), shipmentHandler.CreateShipment)
+			shipments.GET("/:id", middleware.RequirePermission(auth.PermissionShipmentsRead), shipmentHandler.GetShipment)
 			shipments.GET("/track/:trackingNumber", shipmentHandler.TrackShipment)
-			shipments.POST("/:id/label", shipmentHandler.GenerateLabel)
-			shipments.PUT("/:id/cancel", shipmentHandler.CancelShipment)
+			shipments.POST("/:id/label", middleware.RequirePermission(auth.PermissionShipmentsLabel), shipmentHandler.GenerateLabel)
+			shipments.PUT("/:id/cancel", middleware.RequirePermission(auth.PermissionShipmentsCancel), shipmentHandler.CancelShipment)
 		}
 	}
 
@@ -143,3 +159,14 @@ func setupRouter(cfg *config.Config, pvzHandler *handlers.PVZHandler, shipmentHa
 
 	return router
 }
+
+// splitList parses a comma-separated setting, nil when it is empty
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
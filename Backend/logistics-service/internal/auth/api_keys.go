@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"logistics-service/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// TokenTypeAPIKey marks claims standing in for a tenant API key
+const TokenTypeAPIKey = "api_key"
+
+// apiKeyTouchInterval matches auth-service: last-used is written at most
+// once a minute per key
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid, revoked or expired API key")
+	ErrAPIKeyIPNotAllowed = errors.New("API key is not allowed from this address")
+)
+
+// APIKeyVerifier checks the tenant API keys issued by auth-service against
+// the api_keys table of the shared database. Keys are "pk_<id>.<secret>"
+// and stored as a SHA-256 hash under their prefix.
+type APIKeyVerifier struct {
+	db *database.TenantDB
+}
+
+func NewAPIKeyVerifier(db *database.TenantDB) *APIKeyVerifier {
+	return &APIKeyVerifier{db: db}
+}
+
+// Verify checks the key presented from ip and returns claims with the key's
+// tenant and its scopes as permissions
+func (v *APIKeyVerifier) Verify(ctx context.Context, rawKey, ip string) (*Claims, error) {
+	prefix, _, ok := strings.Cut(rawKey, ".")
+	if !ok || !strings.HasPrefix(prefix, "pk_") {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var (
+		id         uuid.UUID
+		tenantID   uuid.UUID
+		keyHash    string
+		scopes     []string
+		allowedIPs []string
+		expiresAt  *time.Time
+		revokedAt  *time.Time
+	)
+
+	// Prefixes are unique across tenants, the key determines the tenant
+	err := v.db.Run(database.WithPlatformScope(ctx), func(q database.Querier) error {
+		return q.QueryRowContext(ctx, `
+			SELECT id, tenant_id, key_hash, scopes, allowed_ips, expires_at, revoked_at
+			FROM api_keys
+			WHERE prefix = $1
+		`, prefix).Scan(&id, &tenantID, &keyHash, pq.Array(&scopes), pq.Array(&allowedIPs), &expiresAt, &revokedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(rawKey))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(keyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if revokedAt != nil || (expiresAt != nil && time.Now().After(*expiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(allowedIPs, ip) {
+		return nil, ErrAPIKeyIPNotAllowed
+	}
+
+	_, err = v.db.Exec(database.WithTenant(ctx, tenantID), `
+		UPDATE api_keys
+		SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, id, ip, time.Now().Add(-apiKeyTouchInterval))
+	if err != nil {
+		log.Printf("Failed to record use of API key %s: %v", prefix, err)
+	}
+
+	claims := &Claims{
+		TenantID:    tenantID.String(),
+		Type:        TokenTypeAPIKey,
+		Scope:       strings.Join(scopes, " "),
+		Permissions: scopes,
+		APIKeyID:    id.String(),
+	}
+	claims.Subject = prefix
+
+	return claims, nil
+}
+
+// ipAllowed reports whether ip matches the allow-list of IPs and CIDRs. An
+// empty list allows every address.
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedAddr := net.ParseIP(entry); allowedAddr != nil && allowedAddr.Equal(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,41 @@
+package auth
+
+// Permissions checked by logistics-service. Names must match the catalogue
+// in auth-service (internal/service/permissions.go).
+const (
+	PermissionShipmentsRead   = "shipments:read"
+	PermissionShipmentsCreate = "shipments:create"
+	PermissionShipmentsCancel = "shipments:cancel"
+	PermissionShipmentsLabel  = "shipments:label"
+
+	PermissionPVZRead = "pvz:read"
+	PermissionPVZSync = "pvz:sync"
+)
+
+// rolePermissions are the logistics permissions of the built-in roles, for
+// tokens issued before permissions were embedded. Must match builtinRoles
+// in auth-service.
+var rolePermissions = map[string][]string{
+	"super_admin": {"*"},
+	"tenant_admin": {
+		PermissionShipmentsRead,
+		PermissionShipmentsCreate,
+		PermissionShipmentsCancel,
+		PermissionShipmentsLabel,
+		PermissionPVZRead,
+		PermissionPVZSync,
+	},
+	"vendor": {
+		PermissionShipmentsRead,
+		PermissionShipmentsCreate,
+		PermissionShipmentsLabel,
+		PermissionPVZRead,
+	},
+	"operator": {
+		PermissionShipmentsRead,
+		PermissionPVZRead,
+	},
+	"customer": {
+		PermissionPVZRead,
+	},
+}
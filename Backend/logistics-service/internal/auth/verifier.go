@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Keys are refetched periodically and when an unknown kid shows up,
+	// but not more often than jwksMinRefresh
+	jwksMaxAge     = 5 * time.Minute
+	jwksMinRefresh = 30 * time.Second
+)
+
+// Revocations written by auth-service to the shared Redis, see
+// TokenDenylist there
+const (
+	revokedTokenPrefix   = "revoked_token:"
+	revokedBeforePrefix  = "tokens_revoked_before:"
+	revokedSessionPrefix = "revoked_session:"
+)
+
+// Token types accepted by Verify
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeService = "service"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// Claims mirrors the access token claims issued by auth-service
+type Claims struct {
+	UserID      string   `json:"user_id"`
+	TenantID    string   `json:"tenant_id"`
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	Type        string   `json:"type,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	// PVZID is set for staff limited to one pickup point
+	PVZID string `json:"pvz_id,omitempty"`
+	// ClientID and Scope are set on service tokens of machine clients,
+	// which carry no user. Their scopes are also in Permissions.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// APIKeyID is set on claims standing in for an API key, see
+	// APIKeyVerifier
+	APIKeyID string `json:"-"`
+	// Act is set on impersonation tokens and names the operator acting as
+	// the user (RFC 8693)
+	Act *ActorClaim `json:"act,omitempty"`
+
+	jwt.RegisteredClaims
+}
+
+// ActorClaim identifies the operator behind an impersonation token
+type ActorClaim struct {
+	Subject  string `json:"sub"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// Verifier validates access tokens against the JWKS published by
+// auth-service and rejects tokens auth-service revoked: single tokens,
+// ended sessions and everything issued before a logout-all
+type Verifier struct {
+	jwksURL string
+	issuer  string
+	client  *http.Client
+	redis   *redis.Client
+
+	mu        sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+func NewVerifier(jwksURL, issuer string, redis *redis.Client) *Verifier {
+	return &Verifier{
+		jwksURL: jwksURL,
+		issuer:  issuer,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		redis:   redis,
+		keys:    map[string]interface{}{},
+	}
+}
+
+// Verify parses the token and checks signature, expiry, issuer, type and
+// revocation
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA"}),
+		jwt.WithIssuer(v.issuer),
+	)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// Refresh and ID tokens are signed with the same keys, only access and
+	// service tokens authorize requests
+	if claims.Type != TokenTypeAccess && claims.Type != TokenTypeService {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := v.isRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// isRevoked checks the revocations auth-service keeps in Redis
+func (v *Verifier) isRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	pipe := v.redis.Pipeline()
+	tokenCmd := pipe.Exists(ctx, revokedTokenPrefix+claims.ID)
+	sessionCmd := pipe.Exists(ctx, revokedSessionPrefix+claims.SessionID)
+	watermarkCmd := pipe.Get(ctx, revokedBeforePrefix+claims.UserID)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if tokenCmd.Val() > 0 || (claims.SessionID != "" && sessionCmd.Val() > 0) {
+		return true, nil
+	}
+
+	if claims.UserID == "" || claims.IssuedAt == nil {
+		return false, nil
+	}
+	revokedBefore, err := watermarkCmd.Int64()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// iat has whole seconds, a token issued in the second of the revocation
+	// may predate it
+	return claims.IssuedAt.Unix() <= revokedBefore, nil
+}
+
+// EffectivePermissions returns the permissions carried by the claims, or the
+// defaults of the role for tokens issued before permissions were embedded.
+// Same semantics as in auth-service.
+func (c *Claims) EffectivePermissions() []string {
+	if len(c.Permissions) > 0 || c.IsClientBound() {
+		return c.Permissions
+	}
+	return rolePermissions[c.Role]
+}
+
+// IsClientBound reports whether a user's token was issued to an OAuth
+// client rather than to the platform's own apps
+func (c *Claims) IsClientBound() bool {
+	return !c.IsService() && c.ClientID != ""
+}
+
+// IsService reports whether the token was issued to a machine client
+func (c *Claims) IsService() bool {
+	return c.Type == TokenTypeService
+}
+
+// IsImpersonated reports whether an operator is acting as the user
+func (c *Claims) IsImpersonated() bool {
+	return c.Act != nil
+}
+
+// IsAPIKey reports whether the request authenticated with a tenant API key
+func (c *Claims) IsAPIKey() bool {
+	return c.Type == TokenTypeAPIKey
+}
+
+// IsPlatform reports whether the caller works across tenants: super admins
+// and platform-wide services
+func (c *Claims) IsPlatform() bool {
+	return (c.Role == "super_admin" && !c.IsClientBound()) || (c.IsService() && c.TenantID == "")
+}
+
+func (v *Verifier) key(ctx context.Context, kid string) (interface{}, error) {
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	age := time.Since(v.fetchedAt)
+	v.mu.RUnlock()
+
+	if ok && age < jwksMaxAge {
+		return key, nil
+	}
+
+	if age >= jwksMinRefresh {
+		if err := v.refresh(ctx); err != nil && !ok {
+			return nil, err
+		}
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %s", kid)
+}
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+}
+
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.KeyID] = key
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+}
+
+// HasPermission reports whether the granted set satisfies the required
+// permission. "*" grants everything and "<resource>:*" every action on the
+// resource. Same semantics as in auth-service.
+func HasPermission(granted []string, required string) bool {
+	resource := required
+	if i := strings.Index(required, ":"); i >= 0 {
+		resource = required[:i]
+	}
+
+	for _, permission := range granted {
+		if permission == "*" || permission == required || permission == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrNoTenantScope is returned for queries whose context carries neither a
+// tenant nor the platform scope. Queries fail closed instead of running
+// without row-level security.
+var ErrNoTenantScope = errors.New("database: no tenant scope in context")
+
+// Querier is implemented by *sql.DB and *sql.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type scopeContextKey struct{}
+
+type scope struct {
+	tenantID uuid.UUID
+	platform bool
+}
+
+// WithTenant returns a copy of ctx whose queries only see rows of the tenant
+func WithTenant(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, scope{tenantID: tenantID})
+}
+
+// WithPlatformScope returns a copy of ctx whose queries bypass row-level
+// security. Only use it for platform-admin operations and for lookups by
+// globally unique keys that establish the tenant.
+func WithPlatformScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, scope{platform: true})
+}
+
+// TenantDB runs queries in transactions scoped to the tenant of the context.
+// Row-level security applies to the tenant role only: the connection user
+// owns the tables and bypasses it, which is what the platform scope uses.
+type TenantDB struct {
+	db   *sql.DB
+	role string
+}
+
+// NewTenantDB wraps db. role is the database role without table ownership
+// that tenant-scoped transactions switch to, see init-db.sql.
+func NewTenantDB(db *sql.DB, role string) *TenantDB {
+	return &TenantDB{db: db, role: role}
+}
+
+// Run calls fn inside a transaction scoped by ctx and commits it if fn
+// succeeds. Rows must be consumed within fn.
+func (t *TenantDB) Run(ctx context.Context, fn func(q Querier) error) error {
+	s, ok := ctx.Value(scopeContextKey{}).(scope)
+	if !ok {
+		return ErrNoTenantScope
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if !s.platform {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+pq.QuoteIdentifier(t.role)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "SELECT set_config('app.current_tenant', $1, true)", s.tenantID.String()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Exec runs a single statement in a transaction scoped by ctx
+func (t *TenantDB) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := t.Run(ctx, func(q Querier) error {
+		var err error
+		result, err = q.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
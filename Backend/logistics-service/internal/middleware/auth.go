@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"logistics-service/internal/auth"
+	"logistics-service/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const ClaimsKey contextKey = "claims"
+
+// AuthMiddleware validates the bearer access token issued by auth-service,
+// or the tenant API key of "Authorization: ApiKey <key>"
+func AuthMiddleware(verifier *auth.Verifier, apiKeys *auth.APIKeyVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenParts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(tokenParts) != 2 || (tokenParts[0] != "Bearer" && tokenParts[0] != "ApiKey") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			c.Abort()
+			return
+		}
+
+		var claims *auth.Claims
+		var err error
+		if tokenParts[0] == "ApiKey" {
+			claims, err = apiKeys.Verify(c.Request.Context(), tokenParts[1], c.ClientIP())
+			if err == auth.ErrAPIKeyIPNotAllowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+				c.Abort()
+				return
+			}
+		} else {
+			claims, err = verifier.Verify(c.Request.Context(), tokenParts[1])
+			if err == auth.ErrTokenRevoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("tenant_id", claims.TenantID)
+		c.Set("role", claims.Role)
+		c.Set("permissions", claims.EffectivePermissions())
+		if claims.IsService() {
+			c.Set("client_id", claims.ClientID)
+		}
+		if claims.IsAPIKey() {
+			c.Set("api_key_id", claims.APIKeyID)
+		}
+		// Impersonation tokens act as user_id on behalf of actor_id
+		if claims.IsImpersonated() {
+			c.Set("actor_id", claims.Act.Subject)
+		}
+
+		// Handlers are plain net/http and read the claims from the context
+		ctx := context.WithValue(c.Request.Context(), ClaimsKey, claims)
+
+		// Scope queries for row-level security: super admins and
+		// platform-wide services work across tenants, everyone else only
+		// sees their own tenant's rows
+		if claims.IsPlatform() {
+			ctx = database.WithPlatformScope(ctx)
+		} else {
+			tenantID, err := uuid.Parse(claims.TenantID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			ctx = database.WithTenant(ctx, tenantID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// RequirePermission allows the request only if the caller holds every
+// listed permission. Must run after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c.Request.Context())
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User permissions not found"})
+			c.Abort()
+			return
+		}
+
+		for _, permission := range permissions {
+			if !auth.HasPermission(claims.EffectivePermissions(), permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "required": permission})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope allows only service tokens and API keys granted every
+// listed scope, for endpoints meant for machine clients and integrations.
+// Must run after AuthMiddleware.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c.Request.Context())
+		if claims == nil || (!claims.IsService() && !claims.IsAPIKey()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Service token required"})
+			c.Abort()
+			return
+		}
+
+		granted := strings.Fields(claims.Scope)
+		for _, scope := range scopes {
+			if !auth.HasPermission(granted, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "required": scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// GetClaims retrieves the caller's token claims from context
+func GetClaims(ctx context.Context) *auth.Claims {
+	if claims, ok := ctx.Value(ClaimsKey).(*auth.Claims); ok {
+		return claims
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PVZLocation is a pickup point of a logistics provider, or one a tenant
+// runs itself (provider "custom")
+type PVZLocation struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	TenantID     uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	Provider     string    `json:"provider" db:"provider"`
+	ExternalID   *string   `json:"external_id,omitempty" db:"external_id"`
+	Code         *string   `json:"code,omitempty" db:"code"`
+	Name         string    `json:"name" db:"name"`
+	Address      string    `json:"address" db:"address"`
+	City         string    `json:"city" db:"city"`
+	PostalCode   *string   `json:"postal_code,omitempty" db:"postal_code"`
+	Latitude     *float64  `json:"latitude,omitempty" db:"latitude"`
+	Longitude    *float64  `json:"longitude,omitempty" db:"longitude"`
+	WorkingHours *string   `json:"working_hours,omitempty" db:"working_hours"` // JSON string
+	Phone        *string   `json:"phone,omitempty" db:"phone"`
+	Email        *string   `json:"email,omitempty" db:"email"`
+	IsActive     bool      `json:"is_active" db:"is_active"`
+	AcceptsCash  bool      `json:"accepts_cash" db:"accepts_cash"`
+	AcceptsCard  bool      `json:"accepts_card" db:"accepts_card"`
+	Metadata     string    `json:"metadata,omitempty" db:"metadata"` // JSON string
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Shipment statuses, in the order a parcel usually goes through them
+const (
+	ShipmentStatusCreated        = "created"
+	ShipmentStatusRegistered     = "registered"
+	ShipmentStatusInTransit      = "in_transit"
+	ShipmentStatusArrivedAtPVZ   = "arrived_at_pvz"
+	ShipmentStatusReadyForPickup = "ready_for_pickup"
+	ShipmentStatusDelivered      = "delivered"
+	ShipmentStatusReturned       = "returned"
+	ShipmentStatusCancelled      = "cancelled"
+)
+
+// Shipment is the delivery of an order through a logistics provider
+type Shipment struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	TenantID       uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	OrderID        *uuid.UUID `json:"order_id,omitempty" db:"order_id"`
+	PVZID          *uuid.UUID `json:"pvz_id,omitempty" db:"pvz_id"`
+	Provider       string     `json:"provider" db:"provider"`
+	TrackingNumber *string    `json:"tracking_number,omitempty" db:"tracking_number"`
+	ExternalID     *string    `json:"external_id,omitempty" db:"external_id"`
+	Status         string     `json:"status" db:"status"`
+	PickupCode     *string    `json:"pickup_code,omitempty" db:"pickup_code"`
+	Barcode        *string    `json:"barcode,omitempty" db:"barcode"`
+	LabelURL       *string    `json:"label_url,omitempty" db:"label_url"`
+	ShippedAt      *time.Time `json:"shipped_at,omitempty" db:"shipped_at"`
+	ArrivedAt      *time.Time `json:"arrived_at,omitempty" db:"arrived_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	Metadata       string     `json:"metadata,omitempty" db:"metadata"` // JSON string
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
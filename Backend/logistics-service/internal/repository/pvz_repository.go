@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"logistics-service/internal/database"
+	"logistics-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// PVZRepository stores pickup points. pvz_locations has row-level security,
+// queries only see the points of the tenant in the context.
+type PVZRepository interface {
+	Create(ctx context.Context, pvz *models.PVZLocation) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.PVZLocation, error)
+	GetByExternalID(ctx context.Context, provider, externalID string) (*models.PVZLocation, error)
+	ListByCity(ctx context.Context, city, provider string) ([]*models.PVZLocation, error)
+	Update(ctx context.Context, pvz *models.PVZLocation) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type pvzRepository struct {
+	db *database.TenantDB
+}
+
+func NewPVZRepository(db *database.TenantDB) PVZRepository {
+	return &pvzRepository{db: db}
+}
+
+const pvzColumns = `id, tenant_id, provider, external_id, code, name, address, city, postal_code, latitude, longitude,
+	working_hours, phone, email, is_active, accepts_cash, accepts_card, COALESCE(metadata, '{}'), created_at, updated_at`
+
+func (r *pvzRepository) Create(ctx context.Context, pvz *models.PVZLocation) error {
+	query := `
+		INSERT INTO pvz_locations (id, tenant_id, provider, external_id, code, name, address, city, postal_code, latitude, longitude,
+			working_hours, phone, email, is_active, accepts_cash, accepts_card, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, COALESCE(NULLIF($18, ''), '{}')::jsonb, $19, $20)
+	`
+
+	pvz.ID = uuid.New()
+	pvz.CreatedAt = time.Now()
+	pvz.UpdatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		pvz.ID,
+		pvz.TenantID,
+		pvz.Provider,
+		pvz.ExternalID,
+		pvz.Code,
+		pvz.Name,
+		pvz.Address,
+		pvz.City,
+		pvz.PostalCode,
+		pvz.Latitude,
+		pvz.Longitude,
+		pvz.WorkingHours,
+		pvz.Phone,
+		pvz.Email,
+		pvz.IsActive,
+		pvz.AcceptsCash,
+		pvz.AcceptsCard,
+		pvz.Metadata,
+		pvz.CreatedAt,
+		pvz.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *pvzRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.PVZLocation, error) {
+	query := `SELECT ` + pvzColumns + ` FROM pvz_locations WHERE id = $1`
+	return r.queryOne(ctx, query, id)
+}
+
+// GetByExternalID looks up a point by the ID its provider gave it
+func (r *pvzRepository) GetByExternalID(ctx context.Context, provider, externalID string) (*models.PVZLocation, error) {
+	query := `SELECT ` + pvzColumns + ` FROM pvz_locations WHERE provider = $1 AND external_id = $2`
+	return r.queryOne(ctx, query, provider, externalID)
+}
+
+// ListByCity returns the active points of the city, of all providers when
+// provider is empty
+func (r *pvzRepository) ListByCity(ctx context.Context, city, provider string) ([]*models.PVZLocation, error) {
+	query := `
+		SELECT ` + pvzColumns + `
+		FROM pvz_locations
+		WHERE city = $1 AND is_active AND ($2 = '' OR provider = $2)
+		ORDER BY name
+	`
+
+	points := []*models.PVZLocation{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, city, provider)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			pvz, err := scanPVZ(rows)
+			if err != nil {
+				return err
+			}
+			points = append(points, pvz)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+func (r *pvzRepository) Update(ctx context.Context, pvz *models.PVZLocation) (bool, error) {
+	query := `
+		UPDATE pvz_locations
+		SET code = $1, name = $2, address = $3, city = $4, postal_code = $5, latitude = $6, longitude = $7,
+			working_hours = $8, phone = $9, email = $10, is_active = $11, accepts_cash = $12, accepts_card = $13,
+			metadata = COALESCE(NULLIF($14, ''), '{}')::jsonb, updated_at = $15
+		WHERE id = $16
+	`
+
+	pvz.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(ctx, query,
+		pvz.Code,
+		pvz.Name,
+		pvz.Address,
+		pvz.City,
+		pvz.PostalCode,
+		pvz.Latitude,
+		pvz.Longitude,
+		pvz.WorkingHours,
+		pvz.Phone,
+		pvz.Email,
+		pvz.IsActive,
+		pvz.AcceptsCash,
+		pvz.AcceptsCard,
+		pvz.Metadata,
+		pvz.UpdatedAt,
+		pvz.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *pvzRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM pvz_locations WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *pvzRepository) queryOne(ctx context.Context, query string, args ...interface{}) (*models.PVZLocation, error) {
+	var pvz *models.PVZLocation
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		pvz, err = scanPVZ(q.QueryRowContext(ctx, query, args...))
+		return err
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return pvz, err
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPVZ(row rowScanner) (*models.PVZLocation, error) {
+	pvz := &models.PVZLocation{}
+	err := row.Scan(
+		&pvz.ID,
+		&pvz.TenantID,
+		&pvz.Provider,
+		&pvz.ExternalID,
+		&pvz.Code,
+		&pvz.Name,
+		&pvz.Address,
+		&pvz.City,
+		&pvz.PostalCode,
+		&pvz.Latitude,
+		&pvz.Longitude,
+		&pvz.WorkingHours,
+		&pvz.Phone,
+		&pvz.Email,
+		&pvz.IsActive,
+		&pvz.AcceptsCash,
+		&pvz.AcceptsCard,
+		&pvz.Metadata,
+		&pvz.CreatedAt,
+		&pvz.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pvz, nil
+}
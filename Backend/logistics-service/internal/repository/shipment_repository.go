@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"logistics-service/internal/database"
+	"logistics-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ShipmentRepository stores shipments. shipments has row-level security,
+// queries only see the shipments of the tenant in the context. Provider
+// webhooks don't know the tenant and look shipments up by tracking number
+// in the platform scope.
+type ShipmentRepository interface {
+	Create(ctx context.Context, shipment *models.Shipment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Shipment, error)
+	GetByTrackingNumber(ctx context.Context, trackingNumber string) (*models.Shipment, error)
+	ListByOrder(ctx context.Context, orderID uuid.UUID) ([]*models.Shipment, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status string) (bool, error)
+}
+
+type shipmentRepository struct {
+	db *database.TenantDB
+}
+
+func NewShipmentRepository(db *database.TenantDB) ShipmentRepository {
+	return &shipmentRepository{db: db}
+}
+
+const shipmentColumns = `id, tenant_id, order_id, pvz_id, provider, tracking_number, external_id, status, pickup_code, barcode, label_url,
+	shipped_at, arrived_at, delivered_at, COALESCE(metadata, '{}'), created_at, updated_at`
+
+func (r *shipmentRepository) Create(ctx context.Context, shipment *models.Shipment) error {
+	query := `
+		INSERT INTO shipments (id, tenant_id, order_id, pvz_id, provider, tracking_number, external_id, status, pickup_code, barcode, label_url,
+			metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), '{}')::jsonb, $13, $14)
+	`
+
+	shipment.ID = uuid.New()
+	if shipment.Status == "" {
+		shipment.Status = models.ShipmentStatusCreated
+	}
+	shipment.CreatedAt = time.Now()
+	shipment.UpdatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		shipment.ID,
+		shipment.TenantID,
+		shipment.OrderID,
+		shipment.PVZID,
+		shipment.Provider,
+		shipment.TrackingNumber,
+		shipment.ExternalID,
+		shipment.Status,
+		shipment.PickupCode,
+		shipment.Barcode,
+		shipment.LabelURL,
+		shipment.Metadata,
+		shipment.CreatedAt,
+		shipment.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *shipmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE id = $1`
+	return r.queryOne(ctx, query, id)
+}
+
+// GetByTrackingNumber looks up a shipment by its globally unique tracking
+// number
+func (r *shipmentRepository) GetByTrackingNumber(ctx context.Context, trackingNumber string) (*models.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE tracking_number = $1`
+	return r.queryOne(ctx, query, trackingNumber)
+}
+
+func (r *shipmentRepository) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]*models.Shipment, error) {
+	query := `SELECT ` + shipmentColumns + ` FROM shipments WHERE order_id = $1 ORDER BY created_at`
+
+	shipments := []*models.Shipment{}
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		rows, err := q.QueryContext(ctx, query, orderID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			shipment, err := scanShipment(rows)
+			if err != nil {
+				return err
+			}
+			shipments = append(shipments, shipment)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipments, nil
+}
+
+// UpdateStatus moves the shipment to status and stamps when it was shipped,
+// arrived at the pickup point or was delivered
+func (r *shipmentRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) (bool, error) {
+	query := `
+		UPDATE shipments
+		SET status = $1,
+			shipped_at = CASE WHEN $1 = 'in_transit' THEN COALESCE(shipped_at, $2) ELSE shipped_at END,
+			arrived_at = CASE WHEN $1 = 'arrived_at_pvz' THEN COALESCE(arrived_at, $2) ELSE arrived_at END,
+			delivered_at = CASE WHEN $1 = 'delivered' THEN COALESCE(delivered_at, $2) ELSE delivered_at END,
+			updated_at = $2
+		WHERE id = $3
+	`
+
+	result, err := r.db.Exec(ctx, query, status, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *shipmentRepository) queryOne(ctx context.Context, query string, args ...interface{}) (*models.Shipment, error) {
+	var shipment *models.Shipment
+	err := r.db.Run(ctx, func(q database.Querier) error {
+		var err error
+		shipment, err = scanShipment(q.QueryRowContext(ctx, query, args...))
+		return err
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return shipment, err
+}
+
+func scanShipment(row rowScanner) (*models.Shipment, error) {
+	shipment := &models.Shipment{}
+	err := row.Scan(
+		&shipment.ID,
+		&shipment.TenantID,
+		&shipment.OrderID,
+		&shipment.PVZID,
+		&shipment.Provider,
+		&shipment.TrackingNumber,
+		&shipment.ExternalID,
+		&shipment.Status,
+		&shipment.PickupCode,
+		&shipment.Barcode,
+		&shipment.LabelURL,
+		&shipment.ShippedAt,
+		&shipment.ArrivedAt,
+		&shipment.DeliveredAt,
+		&shipment.Metadata,
+		&shipment.CreatedAt,
+		&shipment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
@@ -27,32 +27,69 @@ CREATE INDEX idx_tenants_custom_domain ON tenants(custom_domain);
 CREATE INDEX idx_tenants_status ON tenants(status);
 CREATE INDEX idx_tenants_country ON tenants(country);
 
+-- ========================================
+-- ROLES TABLE (per-tenant custom roles)
+-- ========================================
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(tenant_id, name)
+);
+
+CREATE INDEX idx_roles_tenant_id ON roles(tenant_id);
+
+ALTER TABLE roles ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_roles ON roles
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
 -- ========================================
 -- USERS TABLE
 -- ========================================
 CREATE TABLE IF NOT EXISTS users (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
-    email VARCHAR(255) NOT NULL,
+    email VARCHAR(255),  -- NULL for users created by phone login
     phone VARCHAR(20),
     password_hash VARCHAR(255),
     first_name VARCHAR(100),
     last_name VARCHAR(100),
     avatar_url TEXT,
     role VARCHAR(50) NOT NULL DEFAULT 'customer' CHECK (role IN ('super_admin', 'tenant_admin', 'vendor', 'customer', 'operator')),
+    custom_role_id UUID REFERENCES roles(id) ON DELETE SET NULL,  -- extra permissions on top of role
+    pvz_id UUID,  -- pickup point a staff member is limited to, see fk_users_pvz_id
     is_verified BOOLEAN DEFAULT FALSE,
     is_active BOOLEAN DEFAULT TRUE,
     last_login_at TIMESTAMP WITH TIME ZONE,
     metadata JSONB DEFAULT '{}',
+    -- Erasure on request of the user: PII is removed once the grace period
+    -- ends, the row stays for the records that reference it
+    erasure_requested_at TIMESTAMP WITH TIME ZONE,
+    erasure_scheduled_at TIMESTAMP WITH TIME ZONE,
+    anonymized_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    UNIQUE(tenant_id, email)
+    UNIQUE(tenant_id, email),
+    -- Super admins are platform-level identities that belong to no tenant
+    CHECK ((tenant_id IS NULL) = (role = 'super_admin'))
 );
 
 CREATE INDEX idx_users_tenant_id ON users(tenant_id);
+CREATE UNIQUE INDEX idx_users_platform_email ON users(email) WHERE tenant_id IS NULL;
 CREATE INDEX idx_users_email ON users(email);
 CREATE INDEX idx_users_phone ON users(phone);
+CREATE UNIQUE INDEX idx_users_tenant_phone ON users(tenant_id, phone) WHERE phone IS NOT NULL;
 CREATE INDEX idx_users_role ON users(role);
+CREATE INDEX idx_users_erasure_scheduled_at ON users(erasure_scheduled_at) WHERE erasure_scheduled_at IS NOT NULL;
+CREATE INDEX idx_users_tenant_created_at ON users(tenant_id, created_at DESC, id DESC);
+-- Admin user search matches substrings of email, phone and full name
+CREATE INDEX idx_users_email_trgm ON users USING GIN(email gin_trgm_ops);
+CREATE INDEX idx_users_phone_trgm ON users USING GIN(phone gin_trgm_ops);
+CREATE INDEX idx_users_name_trgm ON users USING GIN((COALESCE(first_name, '') || ' ' || COALESCE(last_name, '')) gin_trgm_ops);
 
 -- Enable Row Level Security
 ALTER TABLE users ENABLE ROW LEVEL SECURITY;
@@ -61,6 +98,135 @@ ALTER TABLE users ENABLE ROW LEVEL SECURITY;
 CREATE POLICY tenant_isolation_users ON users
     USING (tenant_id = current_setting('app.current_tenant')::uuid);
 
+-- ========================================
+-- PASSWORD HISTORY TABLE (reuse checks and password age of tenant policies)
+-- ========================================
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,  -- the newest entry is the current password
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_password_history_user_id ON password_history(user_id, created_at DESC);
+
+ALTER TABLE password_history ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_password_history ON password_history
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
+-- ========================================
+-- USER MFA TABLE (TOTP two-factor authentication)
+-- ========================================
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    totp_secret TEXT NOT NULL,  -- AES-GCM encrypted
+    is_enabled BOOLEAN DEFAULT FALSE,
+    recovery_codes TEXT[] DEFAULT '{}',  -- SHA-256 hashes of unused codes
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_mfa_tenant_id ON user_mfa(tenant_id);
+
+ALTER TABLE user_mfa ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_user_mfa ON user_mfa
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
+-- ========================================
+-- OAUTH CLIENTS TABLE (OpenID Connect relying parties)
+-- ========================================
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,  -- NULL for platform-wide machine clients
+    client_id VARCHAR(64) UNIQUE NOT NULL,
+    client_secret_hash VARCHAR(64),  -- SHA-256, NULL for public clients
+    name VARCHAR(255) NOT NULL,
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    allowed_scopes TEXT[] NOT NULL DEFAULT '{openid,profile,email,phone}',
+    grant_types TEXT[] NOT NULL DEFAULT '{authorization_code,refresh_token}',  -- '{client_credentials}' for machine clients
+    is_public BOOLEAN DEFAULT FALSE,  -- SPA / mobile apps without a secret
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_oauth_clients_tenant_id ON oauth_clients(tenant_id);
+
+ALTER TABLE oauth_clients ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_oauth_clients ON oauth_clients
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
+-- ========================================
+-- API KEYS TABLE (tenant integrations such as ERP and 1C)
+-- ========================================
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) UNIQUE NOT NULL,  -- shown in listings, identifies the key on use
+    key_hash VARCHAR(64) NOT NULL,  -- SHA-256 of the whole key
+    scopes TEXT[] NOT NULL DEFAULT '{}',  -- permissions granted to the key
+    allowed_ips TEXT[] NOT NULL DEFAULT '{}',  -- IPs or CIDRs, empty allows any
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_tenant_id ON api_keys(tenant_id, created_at DESC);
+
+ALTER TABLE api_keys ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_api_keys ON api_keys
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
+-- ========================================
+-- USER IMPORT JOBS (bulk onboarding of tenant users)
+-- ========================================
+CREATE TABLE IF NOT EXISTS user_import_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    format VARCHAR(10) NOT NULL,  -- csv, jsonl
+    send_invitations BOOLEAN DEFAULT FALSE,  -- email a set-password link to users imported without a hash
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, running, completed, failed
+    payload BYTEA,  -- uploaded file, cleared once the job finishes
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    skipped_rows INTEGER NOT NULL DEFAULT 0,  -- already registered
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_user_import_jobs_tenant_id ON user_import_jobs(tenant_id, created_at DESC);
+CREATE INDEX idx_user_import_jobs_status ON user_import_jobs(status, created_at) WHERE status IN ('pending', 'running');
+
+CREATE TABLE IF NOT EXISTS user_import_errors (
+    job_id UUID NOT NULL REFERENCES user_import_jobs(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    email VARCHAR(255),
+    message TEXT NOT NULL,
+    PRIMARY KEY (job_id, row_number)
+);
+
+ALTER TABLE user_import_jobs ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_user_import_jobs ON user_import_jobs
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
+ALTER TABLE user_import_errors ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_user_import_errors ON user_import_errors
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
 -- ========================================
 -- CATEGORIES TABLE
 -- ========================================
@@ -255,6 +421,38 @@ ALTER TABLE pvz_locations ENABLE ROW LEVEL SECURITY;
 CREATE POLICY tenant_isolation_pvz_locations ON pvz_locations
     USING (tenant_id = current_setting('app.current_tenant')::uuid);
 
+ALTER TABLE users ADD CONSTRAINT fk_users_pvz_id
+    FOREIGN KEY (pvz_id) REFERENCES pvz_locations(id) ON DELETE SET NULL;
+
+-- ========================================
+-- INVITATIONS TABLE (staff onboarding with a pre-assigned role)
+-- ========================================
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    phone VARCHAR(20),
+    role VARCHAR(50) NOT NULL CHECK (role IN ('tenant_admin', 'vendor', 'customer', 'operator')),
+    pvz_id UUID REFERENCES pvz_locations(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, accepted, revoked
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    accepted_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (email IS NOT NULL OR phone IS NOT NULL)
+);
+
+CREATE INDEX idx_invitations_tenant_id ON invitations(tenant_id, created_at DESC);
+CREATE INDEX idx_invitations_pending_email ON invitations(tenant_id, email) WHERE status = 'pending';
+CREATE INDEX idx_invitations_pending_phone ON invitations(tenant_id, phone) WHERE status = 'pending';
+
+ALTER TABLE invitations ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_invitations ON invitations
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
 -- ========================================
 -- SHIPMENTS TABLE
 -- ========================================
@@ -434,6 +632,7 @@ CREATE TABLE IF NOT EXISTS audit_logs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
     user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL,  -- operator acting as user_id
     
     action VARCHAR(50) NOT NULL,
     resource_type VARCHAR(50) NOT NULL,
@@ -450,8 +649,35 @@ CREATE TABLE IF NOT EXISTS audit_logs (
 
 CREATE INDEX idx_audit_logs_tenant_id ON audit_logs(tenant_id);
 CREATE INDEX idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX idx_audit_logs_impersonator_id ON audit_logs(impersonator_id) WHERE impersonator_id IS NOT NULL;
 CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at DESC);
 CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_resource ON audit_logs(resource_type, resource_id);
+
+-- Tenant admins only see their own tenant's trail; platform events have no tenant
+ALTER TABLE audit_logs ENABLE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation_audit_logs ON audit_logs
+    USING (tenant_id = current_setting('app.current_tenant')::uuid);
+
+-- ========================================
+-- TENANT ROLE (Row Level Security)
+-- ========================================
+-- The services connect as the owner of the tables, to which the policies
+-- above do not apply. Tenant-scoped transactions switch to this role with
+-- SET LOCAL ROLE after setting app.current_tenant; platform-wide operations
+-- of super admins stay on the owner.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'platform_app') THEN
+        CREATE ROLE platform_app NOLOGIN;
+    END IF;
+END
+$$;
+
+GRANT platform_app TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO platform_app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO platform_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO platform_app;
 
 -- ========================================
 -- FUNCTIONS & TRIGGERS
@@ -514,7 +740,7 @@ VALUES (
 INSERT INTO users (id, tenant_id, email, password_hash, first_name, last_name, role, is_verified, is_active)
 VALUES (
     '00000000-0000-0000-0000-000000000001',
-    '00000000-0000-0000-0000-000000000000',
+    NULL,  -- platform-level identity
     'admin@platform.com',
     crypt('admin123', gen_salt('bf')),  -- Change this in production!
     'Super',